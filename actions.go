@@ -100,6 +100,9 @@ func ReadActionsYaml(r io.Reader) (*Actions, error) {
 	if err != nil {
 		return nil, err
 	}
+	if data, err = normalizeYAML("actions", data); err != nil {
+		return nil, err
+	}
 
 	result := &Actions{
 		ActionSpecs: map[string]ActionSpec{},
@@ -752,6 +752,16 @@ snapshot:
 	}
 }
 
+func (s *ActionsSuite) TestReadActionsYamlEncoding(c *gc.C) {
+	actions, err := ReadActionsYaml(bytes.NewBufferString("\ufeffsnapshot:\n  description: Take a snapshot.\n"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(actions.ActionSpecs["snapshot"].Description, gc.Equals, "Take a snapshot.")
+
+	_, err = ReadActionsYaml(bytes.NewBufferString("snapshot:\n  description: \xfe\n"))
+	c.Assert(err, gc.ErrorMatches, `actions.yaml: invalid UTF-8 at byte offset 25 .*`)
+	c.Assert(IsEncodingError(err), jc.IsTrue)
+}
+
 func (s *ActionsSuite) TestRecurseMapOnKeys(c *gc.C) {
 	tests := []struct {
 		should     string
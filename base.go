@@ -0,0 +1,120 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/juju/errors"
+)
+
+// Base identifies an operating system and version that a charm
+// runs on, for example "ubuntu/20.04". Bases are intended to
+// replace series names such as "focal".
+type Base struct {
+	// Name holds the name of the operating system, for example "ubuntu".
+	Name string `bson:"name" json:"name"`
+
+	// Channel holds the operating system version, for example "20.04".
+	Channel string `bson:"channel" json:"channel"`
+}
+
+var (
+	validBaseName    = regexp.MustCompile("^[a-z]+$")
+	validBaseChannel = regexp.MustCompile(`^[a-z0-9]+(\.[a-z0-9]+)*$`)
+)
+
+// ParseBase parses a base in the form "name/channel",
+// for example "ubuntu/20.04".
+func ParseBase(s string) (Base, error) {
+	parts := strings.Split(s, "/")
+	if len(parts) != 2 {
+		return Base{}, errors.NotValidf("base %q", s)
+	}
+	b := Base{
+		Name:    parts[0],
+		Channel: parts[1],
+	}
+	if err := b.Validate(); err != nil {
+		return Base{}, errors.Trace(err)
+	}
+	return b, nil
+}
+
+// MustParseBase works like ParseBase, but panics in case of errors.
+func MustParseBase(s string) Base {
+	b, err := ParseBase(s)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// String returns the base in the form "name/channel".
+func (b Base) String() string {
+	return b.Name + "/" + b.Channel
+}
+
+// Validate returns an error if the base is invalid.
+func (b Base) Validate() error {
+	if !validBaseName.MatchString(b.Name) || !validBaseChannel.MatchString(b.Channel) {
+		return errors.NotValidf("base %q", b)
+	}
+	return nil
+}
+
+// Series returns the series name that corresponds to the base,
+// or an error satisfying errors.IsNotFound if there is none.
+func (b Base) Series() (string, error) {
+	if series, ok := baseSeries[b]; ok {
+		return series, nil
+	}
+	return "", errors.NotFoundf("series for base %q", b)
+}
+
+// BaseForSeries returns the base that corresponds to the given
+// series, or an error satisfying errors.IsNotFound if there is none.
+func BaseForSeries(series string) (Base, error) {
+	if b, ok := seriesBases[series]; ok {
+		return b, nil
+	}
+	return Base{}, errors.NotFoundf("base for series %q", series)
+}
+
+// seriesBases holds the base for each known series.
+var seriesBases = map[string]Base{
+	"precise": {"ubuntu", "12.04"},
+	"quantal": {"ubuntu", "12.10"},
+	"raring":  {"ubuntu", "13.04"},
+	"saucy":   {"ubuntu", "13.10"},
+	"trusty":  {"ubuntu", "14.04"},
+	"utopic":  {"ubuntu", "14.10"},
+	"vivid":   {"ubuntu", "15.04"},
+	"wily":    {"ubuntu", "15.10"},
+	"xenial":  {"ubuntu", "16.04"},
+	"yakkety": {"ubuntu", "16.10"},
+	"zesty":   {"ubuntu", "17.04"},
+	"artful":  {"ubuntu", "17.10"},
+	"bionic":  {"ubuntu", "18.04"},
+	"cosmic":  {"ubuntu", "18.10"},
+	"disco":   {"ubuntu", "19.04"},
+	"eoan":    {"ubuntu", "19.10"},
+	"focal":   {"ubuntu", "20.04"},
+	"groovy":  {"ubuntu", "20.10"},
+	"hirsute": {"ubuntu", "21.04"},
+	"impish":  {"ubuntu", "21.10"},
+	"jammy":   {"ubuntu", "22.04"},
+	"centos7": {"centos", "7"},
+	"centos8": {"centos", "8"},
+}
+
+// baseSeries holds the inverse of seriesBases.
+var baseSeries = func() map[Base]string {
+	m := make(map[Base]string)
+	for series, b := range seriesBases {
+		m[b] = series
+	}
+	return m
+}()
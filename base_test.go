@@ -0,0 +1,92 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type BaseSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&BaseSuite{})
+
+var parseBaseTests = []struct {
+	base        string
+	expect      charm.Base
+	expectError string
+}{{
+	base:   "ubuntu/20.04",
+	expect: charm.Base{Name: "ubuntu", Channel: "20.04"},
+}, {
+	base:   "centos/7",
+	expect: charm.Base{Name: "centos", Channel: "7"},
+}, {
+	base:        "ubuntu",
+	expectError: `base "ubuntu" not valid`,
+}, {
+	base:        "ubuntu/20.04/stable",
+	expectError: `base "ubuntu/20.04/stable" not valid`,
+}, {
+	base:        "ubuntu/",
+	expectError: `base "ubuntu/" not valid`,
+}, {
+	base:        "/20.04",
+	expectError: `base "/20.04" not valid`,
+}, {
+	base:        "Ubuntu/20.04",
+	expectError: `base "Ubuntu/20.04" not valid`,
+}, {
+	base:        "ubuntu/20..04",
+	expectError: `base "ubuntu/20..04" not valid`,
+}}
+
+func (s *BaseSuite) TestParseBase(c *gc.C) {
+	for i, test := range parseBaseTests {
+		c.Logf("test %d: %s", i, test.base)
+		base, err := charm.ParseBase(test.base)
+		if test.expectError != "" {
+			c.Check(err, gc.ErrorMatches, test.expectError)
+			c.Check(errors.IsNotValid(err), jc.IsTrue)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(base, gc.Equals, test.expect)
+		c.Check(base.String(), gc.Equals, test.base)
+	}
+}
+
+func (s *BaseSuite) TestMustParseBase(c *gc.C) {
+	c.Assert(charm.MustParseBase("ubuntu/18.04"), gc.Equals, charm.Base{Name: "ubuntu", Channel: "18.04"})
+	c.Assert(func() { charm.MustParseBase("ubuntu") }, gc.PanicMatches, `base "ubuntu" not valid`)
+}
+
+func (s *BaseSuite) TestSeriesConversion(c *gc.C) {
+	for _, series := range []string{"trusty", "xenial", "bionic", "focal", "centos7"} {
+		base, err := charm.BaseForSeries(series)
+		c.Assert(err, jc.ErrorIsNil)
+		back, err := base.Series()
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(back, gc.Equals, series)
+	}
+	base, err := charm.BaseForSeries("focal")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(base, gc.Equals, charm.Base{Name: "ubuntu", Channel: "20.04"})
+}
+
+func (s *BaseSuite) TestUnknownConversions(c *gc.C) {
+	_, err := charm.BaseForSeries("kubernetes")
+	c.Assert(err, gc.ErrorMatches, `base for series "kubernetes" not found`)
+	c.Assert(errors.IsNotFound(err), jc.IsTrue)
+
+	_, err = charm.MustParseBase("ubuntu/20.05").Series()
+	c.Assert(err, gc.ErrorMatches, `series for base "ubuntu/20.05" not found`)
+	c.Assert(errors.IsNotFound(err), jc.IsTrue)
+}
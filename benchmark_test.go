@@ -0,0 +1,161 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+// BenchmarkSuite holds benchmarks for the operations that are most
+// sensitive to performance changes. Run them before and after a
+// change, saving the output of each run:
+//
+//	go test -v -check.b -check.bmem -check.f BenchmarkSuite > old.txt
+//	go test -v -check.b -check.bmem -check.f BenchmarkSuite > new.txt
+//
+// and then compare the two runs with benchstat
+// (golang.org/x/perf/cmd/benchstat). benchstat reads the output
+// of "go test -bench", so strip the gocheck prefix from each
+// result line first:
+//
+//	sed -n 's/^PASS: .*BenchmarkSuite\.//p' old.txt > old.bench
+//	sed -n 's/^PASS: .*BenchmarkSuite\.//p' new.txt > new.bench
+//	benchstat old.bench new.bench
+type BenchmarkSuite struct{}
+
+var _ = gc.Suite(&BenchmarkSuite{})
+
+var benchmarkURLs = []string{
+	"cs:~user/trusty/wordpress-42",
+	"cs:mysql",
+	"cs:bundle/openstack-base-50",
+	"local:xenial/dummy-1",
+	"wordpress",
+}
+
+func (*BenchmarkSuite) BenchmarkParseURL(c *gc.C) {
+	for i := 0; i < c.N; i++ {
+		for _, s := range benchmarkURLs {
+			if _, err := charm.ParseURL(s); err != nil {
+				c.Fatal(err)
+			}
+		}
+	}
+}
+
+func (*BenchmarkSuite) BenchmarkURLString(c *gc.C) {
+	urls := make([]*charm.URL, len(benchmarkURLs))
+	for i, s := range benchmarkURLs {
+		urls[i] = charm.MustParseURL(s)
+	}
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		for _, u := range urls {
+			_ = u.String()
+		}
+	}
+}
+
+func (*BenchmarkSuite) BenchmarkReadMeta(c *gc.C) {
+	data, err := ioutil.ReadFile(filepath.Join(charmDirPath(c, "wordpress"), "metadata.yaml"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.SetBytes(int64(len(data)))
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		if _, err := charm.ReadMeta(bytes.NewReader(data)); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (*BenchmarkSuite) BenchmarkReadConfig(c *gc.C) {
+	data, err := ioutil.ReadFile(filepath.Join(charmDirPath(c, "dummy"), "config.yaml"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.SetBytes(int64(len(data)))
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		if _, err := charm.ReadConfig(bytes.NewReader(data)); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (*BenchmarkSuite) BenchmarkArchiveTo(c *gc.C) {
+	dir := readCharmDir(c, "all-hooks")
+	var buf bytes.Buffer
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		buf.Reset()
+		if err := dir.ArchiveTo(&buf); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (*BenchmarkSuite) BenchmarkExpandTo(c *gc.C) {
+	var buf bytes.Buffer
+	err := readCharmDir(c, "all-hooks").ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	target := c.MkDir()
+	c.SetBytes(int64(buf.Len()))
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		if err := archive.ExpandTo(filepath.Join(target, fmt.Sprint(i))); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (*BenchmarkSuite) BenchmarkReadBundleData(c *gc.C) {
+	data := benchmarkBundle(100)
+	c.SetBytes(int64(len(data)))
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		if _, err := charm.ReadBundleData(strings.NewReader(data)); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+func (*BenchmarkSuite) BenchmarkVerifyBundle(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(benchmarkBundle(100)))
+	c.Assert(err, jc.ErrorIsNil)
+	c.ResetTimer()
+	for i := 0; i < c.N; i++ {
+		if err := bd.Verify(nil, nil, nil); err != nil {
+			c.Fatal(err)
+		}
+	}
+}
+
+// benchmarkBundle returns the YAML for a bundle holding n
+// applications, each placed on its own machine and related
+// to the application before it.
+func benchmarkBundle(n int) string {
+	var buf bytes.Buffer
+	buf.WriteString("series: xenial\napplications:\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "    app%d:\n        charm: cs:xenial/wordpress-%d\n        num_units: 1\n        to: [\"%d\"]\n        options: {blog-title: \"blog %d\"}\n", i, i, i, i)
+	}
+	buf.WriteString("machines:\n")
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, "    \"%d\": {constraints: \"mem=2G\"}\n", i)
+	}
+	buf.WriteString("relations:\n")
+	for i := 1; i < n; i++ {
+		fmt.Fprintf(&buf, "    - [\"app%d:db\", \"app%d:db\"]\n", i-1, i)
+	}
+	return buf.String()
+}
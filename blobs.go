@@ -0,0 +1,243 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// blobsManifest holds the name of the file, at the root of a thin
+// charm archive, that lists the files stored outside the archive.
+const blobsManifest = "blobs.yaml"
+
+// Blob describes a charm file whose content is stored outside
+// the charm archive, so that large payloads need not be
+// carried by every revision of the archive.
+type Blob struct {
+	// Path holds the slash-separated path of the file,
+	// relative to the root of the charm.
+	Path string `yaml:"path"`
+
+	// SHA256 holds the hex-encoded SHA256 hash of the file content.
+	SHA256 string `yaml:"sha256"`
+
+	// Size holds the size of the file content in bytes.
+	Size int64 `yaml:"size"`
+
+	// Executable holds whether the file is executable. As with
+	// files inside the archive, this is the only part of the file
+	// mode that is kept.
+	Executable bool `yaml:"executable,omitempty"`
+}
+
+type blobsManifestData struct {
+	Blobs []Blob `yaml:"blobs"`
+}
+
+// ArchiveThinTo is like ArchiveTo except that the files at the given
+// slash-separated paths, relative to the charm root, are left out of
+// the archive. A manifest recording the hash and size of each of them
+// is added to the archive instead, and the same information is
+// returned so that the caller can store the file contents elsewhere.
+func (dir *CharmDir) ArchiveThinTo(w io.Writer, blobPaths []string) ([]Blob, error) {
+	blobs := make([]Blob, 0, len(blobPaths))
+	exclude := make(map[string]bool)
+	for _, p := range blobPaths {
+		if err := checkBlobPath(p); err != nil {
+			return nil, errors.Trace(err)
+		}
+		if exclude[p] {
+			return nil, errors.Errorf("duplicate blob path %q", p)
+		}
+		blob, err := dir.blob(p)
+		if err != nil {
+			return nil, errors.Trace(err)
+		}
+		blobs = append(blobs, blob)
+		exclude[p] = true
+	}
+	for p := range attachedResourcePaths(dir.Path, dir.meta) {
+		exclude[p] = true
+	}
+	manifest, err := yaml.Marshal(blobsManifestData{blobs})
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	versionString, err := dir.MaybeGenerateVersionString()
+	if err != nil {
+		logger.Warningf("version string generation failed : %v", err)
+	}
+	extra := map[string]string{
+		blobsManifest: string(manifest),
+	}
+	if err := writeArchiveExcluding(w, dir.Path, dir.revision, versionString, dir.Meta().Hooks(), exclude, extra); err != nil {
+		return nil, errors.Trace(err)
+	}
+	return blobs, nil
+}
+
+// blob returns the Blob describing the file at the given
+// slash-separated path inside the charm directory.
+func (dir *CharmDir) blob(p string) (Blob, error) {
+	f, err := os.Open(dir.join(filepath.FromSlash(p)))
+	if err != nil {
+		return Blob{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Blob{}, err
+	}
+	if !info.Mode().IsRegular() {
+		return Blob{}, errors.Errorf("blob %q is not a regular file", p)
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return Blob{}, err
+	}
+	return Blob{
+		Path:       p,
+		SHA256:     fmt.Sprintf("%x", h.Sum(nil)),
+		Size:       size,
+		Executable: info.Mode()&0100 != 0,
+	}, nil
+}
+
+// checkBlobPath returns an error if p cannot be used as the
+// path of an external blob.
+func checkBlobPath(p string) error {
+	switch {
+	case p == "" || path.IsAbs(p) || path.Clean(p) != p:
+		return errors.Errorf("invalid blob path %q", p)
+	case p == ".." || strings.HasPrefix(p, "../"):
+		return errors.Errorf("blob path %q is outside the charm", p)
+	case !strings.Contains(p, "/") && charmRootFiles[p]:
+		return errors.Errorf("charm file %q cannot be stored as a blob", p)
+	}
+	return nil
+}
+
+// charmRootFiles holds the files at the root of a charm that
+// are needed to read it and so must always be in the archive.
+var charmRootFiles = map[string]bool{
+	"metadata.yaml": true,
+	"config.yaml":   true,
+	"metrics.yaml":  true,
+	"actions.yaml":  true,
+	"revision":      true,
+	"version":       true,
+	blobsManifest:   true,
+}
+
+// Blobs returns the files that are stored outside the charm archive,
+// as recorded by CharmDir.ArchiveThinTo. It returns an empty slice
+// if the archive holds all of the charm's files.
+func (a *CharmArchive) Blobs() ([]Blob, error) {
+	zipr, err := a.zopen.openZip()
+	if err != nil {
+		return nil, err
+	}
+	defer zipr.Close()
+	reader, err := zipOpenFile(zipr, blobsManifest)
+	if _, ok := err.(*noCharmArchiveFile); ok {
+		return []Blob{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	var manifest blobsManifestData
+	if err := yaml.Unmarshal(data, &manifest); err != nil {
+		return nil, errors.Annotate(err, "cannot parse blob manifest")
+	}
+	for _, blob := range manifest.Blobs {
+		if err := checkBlobPath(blob.Path); err != nil {
+			return nil, errors.Annotate(err, "invalid blob manifest")
+		}
+	}
+	if manifest.Blobs == nil {
+		return []Blob{}, nil
+	}
+	return manifest.Blobs, nil
+}
+
+// ExpandWithBlobsTo expands the charm archive into dir as ExpandTo
+// does, and then writes the content of each file listed by Blobs.
+// The content of each blob is obtained by calling open; its size
+// and hash are checked against those recorded in the archive.
+func (a *CharmArchive) ExpandWithBlobsTo(dir string, open func(Blob) (io.ReadCloser, error)) error {
+	blobs, err := a.Blobs()
+	if err != nil {
+		return errors.Trace(err)
+	}
+	if err := a.ExpandTo(dir); err != nil {
+		return errors.Trace(err)
+	}
+	for _, blob := range blobs {
+		if err := writeBlob(dir, blob, open); err != nil {
+			return errors.Annotatef(err, "cannot write blob %q", blob.Path)
+		}
+	}
+	return nil
+}
+
+func writeBlob(dir string, blob Blob, open func(Blob) (io.ReadCloser, error)) error {
+	r, err := open(blob)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer r.Close()
+	p := filepath.Join(dir, filepath.FromSlash(blob.Path))
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return errors.Trace(err)
+	}
+	perm := os.FileMode(0644)
+	if blob.Executable {
+		perm = 0755
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	h := sha256.New()
+	// Read one byte more than expected so that we can
+	// tell when the blob is too large.
+	size, err := io.Copy(io.MultiWriter(f, h), io.LimitReader(r, blob.Size+1))
+	if err == nil {
+		err = f.Close()
+	} else {
+		f.Close()
+	}
+	if err == nil {
+		switch hash := fmt.Sprintf("%x", h.Sum(nil)); {
+		case size != blob.Size:
+			err = errors.Errorf("size mismatch: expected %d bytes", blob.Size)
+		case hash != blob.SHA256:
+			err = errors.Errorf("hash mismatch: got %s, expected %s", hash, blob.SHA256)
+		}
+	}
+	if err == nil {
+		// The file may already have existed with a different mode.
+		err = os.Chmod(p, perm)
+	}
+	if err != nil {
+		os.Remove(p)
+		return err
+	}
+	return nil
+}
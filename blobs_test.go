@@ -0,0 +1,213 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type BlobsSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&BlobsSuite{})
+
+const bigFileContent = "pretend this is several gigabytes"
+
+func (s *BlobsSuite) thinArchive(c *gc.C) (*charm.CharmArchive, []charm.Blob) {
+	charmDir := cloneDir(c, charmDirPath(c, "dummy"))
+	err := os.MkdirAll(filepath.Join(charmDir, "payload"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(charmDir, "payload", "big.img"), []byte(bigFileContent), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	dir, err := charm.ReadCharmDir(charmDir)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	blobs, err := dir.ArchiveThinTo(&buf, []string{"payload/big.img"})
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	return archive, blobs
+}
+
+func (s *BlobsSuite) TestArchiveThinTo(c *gc.C) {
+	archive, blobs := s.thinArchive(c)
+	c.Assert(blobs, jc.DeepEquals, []charm.Blob{{
+		Path:   "payload/big.img",
+		SHA256: "908a6655df43b13759adafc7d3d3f90cf6f69cb5e738fd237ca8c96a5926ff3f",
+		Size:   int64(len(bigFileContent)),
+	}})
+	manifest, err := archive.Manifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(manifest.Contains("payload"), jc.IsTrue)
+	c.Assert(manifest.Contains("payload/big.img"), jc.IsFalse)
+	c.Assert(manifest.Contains("blobs.yaml"), jc.IsTrue)
+
+	archived, err := archive.Blobs()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(archived, jc.DeepEquals, blobs)
+}
+
+func (s *BlobsSuite) TestBlobsWithCompleteArchive(c *gc.C) {
+	archive, err := charm.ReadCharmArchive(archivePath(c, readCharmDir(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	blobs, err := archive.Blobs()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(blobs, gc.HasLen, 0)
+}
+
+func (s *BlobsSuite) TestArchiveThinToInvalidPaths(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	for i, test := range []struct {
+		path        string
+		expectError string
+	}{{
+		path:        "/etc/passwd",
+		expectError: `invalid blob path "/etc/passwd"`,
+	}, {
+		path:        "../foo",
+		expectError: `blob path "../foo" is outside the charm`,
+	}, {
+		path:        "src/../config.yaml",
+		expectError: `invalid blob path "src/../config.yaml"`,
+	}, {
+		path:        "metadata.yaml",
+		expectError: `charm file "metadata.yaml" cannot be stored as a blob`,
+	}, {
+		path:        "src",
+		expectError: `blob "src" is not a regular file`,
+	}} {
+		c.Logf("test %d: %s", i, test.path)
+		_, err := dir.ArchiveThinTo(ioutil.Discard, []string{test.path})
+		c.Check(err, gc.ErrorMatches, test.expectError)
+	}
+}
+
+func (s *BlobsSuite) TestExpandWithBlobsTo(c *gc.C) {
+	archive, _ := s.thinArchive(c)
+	target := filepath.Join(c.MkDir(), "charm")
+	err := archive.ExpandWithBlobsTo(target, func(blob charm.Blob) (io.ReadCloser, error) {
+		c.Assert(blob.Path, gc.Equals, "payload/big.img")
+		return ioutil.NopCloser(strings.NewReader(bigFileContent)), nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	data, err := ioutil.ReadFile(filepath.Join(target, "payload", "big.img"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, bigFileContent)
+}
+
+func (s *BlobsSuite) TestExpandWithBlobsToMismatch(c *gc.C) {
+	archive, _ := s.thinArchive(c)
+	for i, content := range []string{
+		"too short",
+		bigFileContent + " and then some",
+		strings.ToUpper(bigFileContent),
+	} {
+		c.Logf("test %d: %q", i, content)
+		target := filepath.Join(c.MkDir(), "charm")
+		err := archive.ExpandWithBlobsTo(target, func(blob charm.Blob) (io.ReadCloser, error) {
+			return ioutil.NopCloser(strings.NewReader(content)), nil
+		})
+		c.Check(err, gc.ErrorMatches, `cannot write blob "payload/big.img": (size|hash) mismatch: .*`)
+		_, err = os.Stat(filepath.Join(target, "payload", "big.img"))
+		c.Check(os.IsNotExist(err), jc.IsTrue)
+	}
+}
+
+func (s *BlobsSuite) TestExpandAndArchiveAgain(c *gc.C) {
+	archive, blobs := s.thinArchive(c)
+	target := filepath.Join(c.MkDir(), "charm")
+	err := archive.ExpandWithBlobsTo(target, func(blob charm.Blob) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(bigFileContent)), nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(target)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// A complete archive of the expanded charm holds the
+	// blob content and does not list any blobs.
+	var buf bytes.Buffer
+	err = dir.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	full, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	fullBlobs, err := full.Blobs()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(fullBlobs, gc.HasLen, 0)
+	manifest, err := full.Manifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(manifest.Contains("payload/big.img"), jc.IsTrue)
+	c.Assert(manifest.Contains("blobs.yaml"), jc.IsFalse)
+
+	// A thin archive of it holds a single manifest.
+	buf.Reset()
+	thinBlobs, err := dir.ArchiveThinTo(&buf, []string{"payload/big.img"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(thinBlobs, jc.DeepEquals, blobs)
+	zipr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, jc.ErrorIsNil)
+	count := 0
+	for _, f := range zipr.File {
+		if f.Name == "blobs.yaml" {
+			count++
+		}
+	}
+	c.Assert(count, gc.Equals, 1)
+}
+
+func (s *BlobsSuite) TestExpandWithBlobsToKeepsExecutable(c *gc.C) {
+	charmDir := cloneDir(c, charmDirPath(c, "dummy"))
+	err := ioutil.WriteFile(filepath.Join(charmDir, "tool"), []byte(bigFileContent), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(charmDir)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	blobs, err := dir.ArchiveThinTo(&buf, []string{"tool"})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(blobs, gc.HasLen, 1)
+	c.Assert(blobs[0].Executable, jc.IsTrue)
+	archive, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+
+	target := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandWithBlobsTo(target, func(blob charm.Blob) (io.ReadCloser, error) {
+		return ioutil.NopCloser(strings.NewReader(bigFileContent)), nil
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	info, err := os.Stat(filepath.Join(target, "tool"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode()&0777, gc.Equals, os.FileMode(0755))
+}
+
+func (s *BlobsSuite) TestArchiveToSkipsReservedManifest(c *gc.C) {
+	charmDir := cloneDir(c, charmDirPath(c, "dummy"))
+	err := ioutil.WriteFile(filepath.Join(charmDir, "blobs.yaml"), []byte("not a manifest"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(charmDir)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	err = dir.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	manifest, err := archive.Manifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(manifest.Contains("blobs.yaml"), jc.IsFalse)
+	blobs, err := archive.Blobs()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(blobs, gc.HasLen, 0)
+}
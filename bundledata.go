@@ -117,6 +117,12 @@ type BundleData struct {
 	// Short paragraph explaining what the bundle is useful for.
 	Description string `bson:",omitempty" json:",omitempty" yaml:",omitempty"`
 
+	// Variables holds the variables that may be referred to as
+	// ${name} in application options and in application and
+	// machine constraints, mapped to their default values.
+	// See SubstituteVariables.
+	Variables map[string]string `bson:",omitempty" json:",omitempty" yaml:",omitempty"`
+
 	// unmarshaledWithServices holds whether the original marshaled data held a
 	// legacy "services" field rather than the "applications" field.
 	unmarshaledWithServices bool
@@ -248,15 +254,54 @@ type ApplicationSpec struct {
 // The returned data is not verified - call Verify to ensure
 // that it is OK.
 func ReadBundleData(r io.Reader) (*BundleData, error) {
+	bd, _, err := ReadBundleDataWithLimits(r, DefaultBundleLimits())
+	return bd, err
+}
+
+// BundleLimits holds limits that protect against bundle data
+// that would be unreasonably large once parsed.
+type BundleLimits struct {
+	// MaxNodes holds the maximum number of YAML nodes that the
+	// bundle data may hold once anchors and aliases have been
+	// expanded. If this is zero, the size is not limited.
+	MaxNodes int
+}
+
+// DefaultBundleLimits returns the limits used by ReadBundleData.
+// They comfortably allow for the largest real-world bundles.
+func DefaultBundleLimits() BundleLimits {
+	return BundleLimits{
+		MaxNodes: 100000,
+	}
+}
+
+// ReadBundleDataWithLimits is like ReadBundleData except that
+// it applies the given limits. It also returns the number of YAML
+// nodes in the bundle data after anchors and aliases have been
+// expanded, which gives an idea of the size of the parsed bundle.
+func ReadBundleDataWithLimits(r io.Reader, limits BundleLimits) (*BundleData, int, error) {
 	bytes, err := ioutil.ReadAll(r)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
+	}
+	if bytes, err = normalizeYAML("bundle", bytes); err != nil {
+		return nil, 0, err
+	}
+	// Count the nodes before unmarshaling for real, so that
+	// we never expand a document that's too large.
+	count, err := countYAMLNodes(bytes, limits.MaxNodes)
+	if err == errYAMLTooLarge {
+		return nil, 0, fmt.Errorf("bundle data too large: more than %d YAML nodes after expanding aliases", limits.MaxNodes)
+	} else if _, ok := err.(*recursiveAliasError); ok {
+		return nil, 0, fmt.Errorf("cannot expand bundle data: %v", err)
+	} else if err != nil {
+		return nil, 0, fmt.Errorf("cannot unmarshal bundle data: %v", err)
 	}
 	var bd BundleData
 	if err := yaml.Unmarshal(bytes, &bd); err != nil {
-		return nil, fmt.Errorf("cannot unmarshal bundle data: %v", err)
+		return nil, 0, fmt.Errorf("cannot unmarshal bundle data: %v", err)
 	}
-	return &bd, nil
+	return &bd, count, nil
 }
 
 // VerificationError holds an error generated by BundleData.Verify,
@@ -275,6 +320,93 @@ func (err *VerificationError) Error() string {
 	return fmt.Sprintf("%s (and %d more errors)", err.Errors[0], len(err.Errors)-1)
 }
 
+// Report returns the verification errors arranged by
+// the part of the bundle that they refer to.
+func (err *VerificationError) Report() *VerificationReport {
+	r := &VerificationReport{}
+	for _, e := range err.Errors {
+		f, ok := e.(*VerificationFinding)
+		if !ok {
+			f = &VerificationFinding{Message: e.Error()}
+		}
+		r.add(f)
+	}
+	return r
+}
+
+// VerificationFinding describes a single problem found when verifying
+// a bundle. The errors held in a VerificationError returned by the
+// bundle verification methods are all of this type.
+type VerificationFinding struct {
+	// Code holds a short machine-readable identifier for the kind
+	// of problem found, for example "invalid-series" or
+	// "unknown-application".
+	Code string `json:"code"`
+
+	// Path holds the dot-separated location in the bundle of the
+	// offending entry, for example "applications.mysql.constraints"
+	// or "relations.2". It is empty if the problem does not
+	// concern a particular entry.
+	Path string `json:"path,omitempty"`
+
+	// Message holds a human-readable description of the problem.
+	Message string `json:"message"`
+}
+
+// Error implements the error interface by returning the message.
+func (f *VerificationFinding) Error() string {
+	return f.Message
+}
+
+// VerificationReport holds the problems found when verifying a bundle,
+// arranged so that each can be related to the bundle entry at fault.
+type VerificationReport struct {
+	// Bundle holds problems with the bundle as a whole.
+	Bundle []*VerificationFinding `json:"bundle,omitempty"`
+
+	// Applications holds problems with each application,
+	// keyed by application name.
+	Applications map[string][]*VerificationFinding `json:"applications,omitempty"`
+
+	// Machines holds problems with each machine, keyed by machine id.
+	Machines map[string][]*VerificationFinding `json:"machines,omitempty"`
+
+	// Relations holds problems with each relation, keyed by the
+	// index of the relation in BundleData.Relations.
+	Relations map[int][]*VerificationFinding `json:"relations,omitempty"`
+}
+
+func (r *VerificationReport) add(f *VerificationFinding) {
+	parts := strings.SplitN(f.Path, ".", 3)
+	if len(parts) < 2 {
+		r.Bundle = append(r.Bundle, f)
+		return
+	}
+	switch parts[0] {
+	case "applications":
+		if r.Applications == nil {
+			r.Applications = make(map[string][]*VerificationFinding)
+		}
+		r.Applications[parts[1]] = append(r.Applications[parts[1]], f)
+		return
+	case "machines":
+		if r.Machines == nil {
+			r.Machines = make(map[string][]*VerificationFinding)
+		}
+		r.Machines[parts[1]] = append(r.Machines[parts[1]], f)
+		return
+	case "relations":
+		if i, err := strconv.Atoi(parts[1]); err == nil {
+			if r.Relations == nil {
+				r.Relations = make(map[int][]*VerificationFinding)
+			}
+			r.Relations[i] = append(r.Relations[i], f)
+			return
+		}
+	}
+	r.Bundle = append(r.Bundle, f)
+}
+
 type bundleDataVerifier struct {
 	// bundleDir is the directory containing the bundle file
 	bundleDir string
@@ -292,12 +424,14 @@ type bundleDataVerifier struct {
 	verifyDevices     func(s string) error
 }
 
-func (verifier *bundleDataVerifier) addErrorf(f string, a ...interface{}) {
-	verifier.addError(fmt.Errorf(f, a...))
-}
-
-func (verifier *bundleDataVerifier) addError(err error) {
-	verifier.errors = append(verifier.errors, err)
+// addErrorf records a problem of the given kind
+// with the bundle entry at the given path.
+func (verifier *bundleDataVerifier) addErrorf(code, path string, f string, a ...interface{}) {
+	verifier.errors = append(verifier.errors, &VerificationFinding{
+		Code:    code,
+		Path:    path,
+		Message: fmt.Sprintf(f, a...),
+	})
 }
 
 func (verifier *bundleDataVerifier) err() error {
@@ -318,6 +452,59 @@ func (bd *BundleData) RequiredCharms() []string {
 	return req
 }
 
+// CharmReference describes a charm referred to by a bundle.
+type CharmReference struct {
+	// Charm holds the charm URL or local charm path
+	// as written in the bundle. Charm URLs that differ only
+	// in form, such as "mysql" and "cs:mysql", share a single
+	// reference, which holds the form used by the first
+	// application, in name order, to refer to the charm.
+	Charm string
+
+	// Paths holds the sorted locations in the bundle that refer
+	// to the charm, in the same form as VerificationFinding.Path;
+	// for example "applications.wordpress.charm".
+	Paths []string
+}
+
+// ReferencedCharms returns an entry for each distinct charm referred
+// to by the bundle, sorted by charm.
+func (bd *BundleData) ReferencedCharms() []CharmReference {
+	byCharm := make(map[string]*CharmReference)
+	for _, appName := range sortedApplicationNames(bd.Applications) {
+		app := bd.Applications[appName]
+		if app == nil {
+			continue
+		}
+		key := app.Charm
+		if !strings.HasPrefix(key, ".") && !filepath.IsAbs(key) {
+			if curl, err := ParseURL(key); err == nil {
+				key = curl.Canonical().String()
+			}
+		}
+		ref := byCharm[key]
+		if ref == nil {
+			ref = &CharmReference{Charm: app.Charm}
+			byCharm[key] = ref
+		}
+		ref.Paths = append(ref.Paths, "applications."+appName+".charm")
+	}
+	refs := make([]CharmReference, 0, len(byCharm))
+	for _, ref := range byCharm {
+		refs = append(refs, *ref)
+	}
+	sort.Sort(charmReferencesByCharm(refs))
+	return refs
+}
+
+// charmReferencesByCharm implements sort.Interface to sort
+// charm references by charm.
+type charmReferencesByCharm []CharmReference
+
+func (r charmReferencesByCharm) Len() int           { return len(r) }
+func (r charmReferencesByCharm) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r charmReferencesByCharm) Less(i, j int) bool { return r[i].Charm < r[j].Charm }
+
 // VerifyLocal verifies that a local bundle file is consistent.
 // A local bundle file may contain references to charms which are
 // referred to by a directory, either relative or absolute.
@@ -409,7 +596,7 @@ func (bd *BundleData) verifyBundle(
 		verifier.machineRefCounts[id] = 0
 	}
 	if bd.Series != "" && !IsValidSeries(bd.Series) {
-		verifier.addErrorf("bundle declares an invalid series %q", bd.Series)
+		verifier.addErrorf("invalid-series", "series", "bundle declares an invalid series %q", bd.Series)
 	}
 	verifier.verifyMachines()
 	verifier.verifyApplications()
@@ -419,7 +606,7 @@ func (bd *BundleData) verifyBundle(
 
 	for id, count := range verifier.machineRefCounts {
 		if count == 0 {
-			verifier.addErrorf("machine %q is not referred to by a placement directive", id)
+			verifier.addErrorf("unused-machine", "machines."+id, "machine %q is not referred to by a placement directive", id)
 		}
 	}
 	return verifier.err()
@@ -433,31 +620,33 @@ var (
 
 func (verifier *bundleDataVerifier) verifyMachines() {
 	for id, m := range verifier.bd.Machines {
+		path := "machines." + id
 		if !validMachineId.MatchString(id) {
-			verifier.addErrorf("invalid machine id %q found in machines", id)
+			verifier.addErrorf("invalid-machine-id", path, "invalid machine id %q found in machines", id)
 		}
 		if m == nil {
 			continue
 		}
 		if m.Constraints != "" {
 			if err := verifier.verifyConstraints(m.Constraints); err != nil {
-				verifier.addErrorf("invalid constraints %q in machine %q: %v", m.Constraints, id, err)
+				verifier.addErrorf("invalid-constraints", path+".constraints", "invalid constraints %q in machine %q: %v", m.Constraints, id, err)
 			}
 		}
 		if m.Series != "" && !IsValidSeries(m.Series) {
-			verifier.addErrorf("invalid series %s for machine %q", m.Series, id)
+			verifier.addErrorf("invalid-series", path+".series", "invalid series %s for machine %q", m.Series, id)
 		}
 	}
 }
 
 func (verifier *bundleDataVerifier) verifyApplications() {
 	if len(verifier.bd.Applications) == 0 {
-		verifier.addErrorf("at least one application must be specified")
+		verifier.addErrorf("no-applications", "applications", "at least one application must be specified")
 		return
 	}
 	for name, svc := range verifier.bd.Applications {
+		path := "applications." + name
 		if svc.Charm == "" {
-			verifier.addErrorf("empty charm path")
+			verifier.addErrorf("invalid-charm", path+".charm", "empty charm path")
 		}
 		// Charm may be a local directory or a charm URL.
 		var curl *URL
@@ -469,96 +658,97 @@ func (verifier *bundleDataVerifier) verifyApplications() {
 			}
 			if _, err := os.Stat(charmPath); err != nil {
 				if os.IsNotExist(err) {
-					verifier.addErrorf("charm path in application %q does not exist: %v", name, charmPath)
+					verifier.addErrorf("charm-not-found", path+".charm", "charm path in application %q does not exist: %v", name, charmPath)
 				} else {
-					verifier.addErrorf("invalid charm path in application %q: %v", name, err)
+					verifier.addErrorf("invalid-charm", path+".charm", "invalid charm path in application %q: %v", name, err)
 				}
 			}
 		} else if curl, err = ParseURL(svc.Charm); err != nil {
-			verifier.addErrorf("invalid charm URL in application %q: %v", name, err)
+			verifier.addErrorf("invalid-charm", path+".charm", "invalid charm URL in application %q: %v", name, err)
 		}
 
 		// Check the Series.
 		if curl != nil && curl.Series != "" && svc.Series != "" && curl.Series != svc.Series {
-			verifier.addErrorf("the charm URL for application %q has a series which does not match, please remove the series from the URL", name)
+			verifier.addErrorf("series-mismatch", path+".series", "the charm URL for application %q has a series which does not match, please remove the series from the URL", name)
 		}
 		if svc.Series != "" && !IsValidSeries(svc.Series) {
-			verifier.addErrorf("application %q declares an invalid series %q", name, svc.Series)
+			verifier.addErrorf("invalid-series", path+".series", "application %q declares an invalid series %q", name, svc.Series)
 		}
 		// Check the Constraints.
 		if err := verifier.verifyConstraints(svc.Constraints); err != nil {
-			verifier.addErrorf("invalid constraints %q in application %q: %v", svc.Constraints, name, err)
+			verifier.addErrorf("invalid-constraints", path+".constraints", "invalid constraints %q in application %q: %v", svc.Constraints, name, err)
 		}
 		// Check the Storage.
 		for storageName, storageConstraints := range svc.Storage {
 			if !validStorageName.MatchString(storageName) {
-				verifier.addErrorf("invalid storage name %q in application %q", storageName, name)
+				verifier.addErrorf("invalid-storage", path+".storage."+storageName, "invalid storage name %q in application %q", storageName, name)
 			}
 			if err := verifier.verifyStorage(storageConstraints); err != nil {
-				verifier.addErrorf("invalid storage %q in application %q: %v", storageName, name, err)
+				verifier.addErrorf("invalid-storage", path+".storage."+storageName, "invalid storage %q in application %q: %v", storageName, name, err)
 			}
 		}
 		// Check the Devices.
 		for deviceName, deviceConstraints := range svc.Devices {
 			if !validDeviceName.MatchString(deviceName) {
-				verifier.addErrorf("invalid device name %q in application %q", deviceName, name)
+				verifier.addErrorf("invalid-device", path+".devices."+deviceName, "invalid device name %q in application %q", deviceName, name)
 			}
 			if err := verifier.verifyDevices(deviceConstraints); err != nil {
-				verifier.addErrorf("invalid device %q in application %q: %v", deviceName, name, err)
+				verifier.addErrorf("invalid-device", path+".devices."+deviceName, "invalid device %q in application %q: %v", deviceName, name, err)
 			}
 		}
 		if verifier.charms != nil {
 			if ch, ok := verifier.charms[svc.Charm]; ok {
 				if ch.Meta().Subordinate {
 					if len(svc.To) > 0 {
-						verifier.addErrorf("application %q is subordinate but specifies unit placement", name)
+						verifier.addErrorf("subordinate-placement", path+".to", "application %q is subordinate but specifies unit placement", name)
 					}
 					if svc.NumUnits > 0 {
-						verifier.addErrorf("application %q is subordinate but has non-zero num_units", name)
+						verifier.addErrorf("subordinate-units", path+".num_units", "application %q is subordinate but has non-zero num_units", name)
 					}
 				}
 			} else {
-				verifier.addErrorf("application %q refers to non-existent charm %q", name, svc.Charm)
+				verifier.addErrorf("charm-not-found", path+".charm", "application %q refers to non-existent charm %q", name, svc.Charm)
 			}
 		}
 		for resName := range svc.Resources {
 			if resName == "" {
-				verifier.addErrorf("missing resource name on application %q", name)
+				verifier.addErrorf("invalid-resource", path+".resources", "missing resource name on application %q", name)
 			}
 			// We do not check the revisions because all values
 			// are allowed.
 		}
 		if svc.NumUnits < 0 {
-			verifier.addErrorf("negative number of units specified on application %q", name)
+			verifier.addErrorf("invalid-num-units", path+".num_units", "negative number of units specified on application %q", name)
 		} else if len(svc.To) > svc.NumUnits {
-			verifier.addErrorf("too many units specified in unit placement for application %q", name)
+			verifier.addErrorf("invalid-placement", path+".to", "too many units specified in unit placement for application %q", name)
 		}
-		verifier.verifyPlacement(svc.To)
+		verifier.verifyPlacement(path+".to", svc.To)
 	}
 }
 
-func (verifier *bundleDataVerifier) verifyPlacement(to []string) {
-	for _, p := range to {
+func (verifier *bundleDataVerifier) verifyPlacement(path string, to []string) {
+	for i, p := range to {
+		path := fmt.Sprintf("%s.%d", path, i)
 		up, err := ParsePlacement(p)
 		if err != nil {
-			verifier.addError(err)
+			verifier.addErrorf("invalid-placement", path, "%v", err)
 			continue
 		}
 		switch {
 		case up.Application != "":
 			spec, ok := verifier.bd.Applications[up.Application]
 			if !ok {
-				verifier.addErrorf("placement %q refers to an application not defined in this bundle", p)
+				verifier.addErrorf("unknown-application", path, "placement %q refers to an application not defined in this bundle", p)
 				continue
 			}
 			if up.Unit >= 0 && up.Unit >= spec.NumUnits {
-				verifier.addErrorf("placement %q specifies a unit greater than the %d unit(s) started by the target application", p, spec.NumUnits)
+				verifier.addErrorf("invalid-placement", path, "placement %q specifies a unit greater than the %d unit(s) started by the target application", p, spec.NumUnits)
 			}
 		case up.Machine == "new":
 		default:
 			_, ok := verifier.bd.Machines[up.Machine]
 			if !ok {
-				verifier.addErrorf("placement %q refers to a machine not defined in this bundle", p)
+				verifier.addErrorf("unknown-machine", path, "placement %q refers to a machine not defined in this bundle", p)
 				continue
 			}
 			verifier.machineRefCounts[up.Machine]++
@@ -569,20 +759,50 @@ func (verifier *bundleDataVerifier) verifyPlacement(to []string) {
 func (verifier *bundleDataVerifier) getCharmMetaForApplication(appName string) (*Meta, error) {
 	svc, ok := verifier.bd.Applications[appName]
 	if !ok {
-		return nil, fmt.Errorf("application %q not found", appName)
+		return nil, inferenceErrorf("unknown-application", "application %q not found", appName)
 	}
 	ch, ok := verifier.charms[svc.Charm]
 	if !ok {
-		return nil, fmt.Errorf("charm %q from application %q not found", svc.Charm, appName)
+		return nil, inferenceErrorf("charm-not-found", "charm %q from application %q not found", svc.Charm, appName)
 	}
 	return ch.Meta(), nil
 }
 
+// inferenceError is returned when the endpoints of a relation
+// cannot be inferred. It holds the verification error code
+// that describes the failure.
+type inferenceError struct {
+	code    string
+	message string
+}
+
+func inferenceErrorf(code string, f string, a ...interface{}) error {
+	return &inferenceError{
+		code:    code,
+		message: fmt.Sprintf(f, a...),
+	}
+}
+
+func (err *inferenceError) Error() string {
+	return err.message
+}
+
+// addInferenceError records an error returned by inferEndpoints
+// for the relation at the given path.
+func (verifier *bundleDataVerifier) addInferenceError(path string, ep0, ep1 endpoint, err error) {
+	code := "invalid-relation"
+	if err, ok := err.(*inferenceError); ok {
+		code = err.code
+	}
+	verifier.addErrorf(code, path, "cannot infer endpoint between %s and %s: %v", ep0, ep1, err)
+}
+
 func (verifier *bundleDataVerifier) verifyRelations() {
 	seen := make(map[[2]endpoint]bool)
-	for _, relPair := range verifier.bd.Relations {
+	for i, relPair := range verifier.bd.Relations {
+		path := fmt.Sprintf("relations.%d", i)
 		if len(relPair) != 2 {
-			verifier.addErrorf("relation %q has %d endpoint(s), not 2", relPair, len(relPair))
+			verifier.addErrorf("invalid-relation", path, "relation %q has %d endpoint(s), not 2", relPair, len(relPair))
 			continue
 		}
 		var epPair [2]endpoint
@@ -590,12 +810,12 @@ func (verifier *bundleDataVerifier) verifyRelations() {
 		for i, svcRel := range relPair {
 			ep, err := parseEndpoint(svcRel)
 			if err != nil {
-				verifier.addError(err)
+				verifier.addErrorf("invalid-relation", path, "%v", err)
 				relParseErr = true
 				continue
 			}
 			if _, ok := verifier.bd.Applications[ep.application]; !ok {
-				verifier.addErrorf("relation %q refers to application %q not defined in this bundle", relPair, ep.application)
+				verifier.addErrorf("unknown-application", path, "relation %q refers to application %q not defined in this bundle", relPair, ep.application)
 			}
 			epPair[i] = ep
 		}
@@ -605,14 +825,14 @@ func (verifier *bundleDataVerifier) verifyRelations() {
 			continue
 		}
 		if epPair[0].application == epPair[1].application {
-			verifier.addErrorf("relation %q relates an application to itself", relPair)
+			verifier.addErrorf("self-relation", path, "relation %q relates an application to itself", relPair)
 		}
 		// Resolve endpoint relations if necessary and we have
 		// the necessary charm information.
 		if (epPair[0].relation == "" || epPair[1].relation == "") && verifier.charms != nil {
 			iep0, iep1, err := inferEndpoints(epPair[0], epPair[1], verifier.getCharmMetaForApplication)
 			if err != nil {
-				verifier.addErrorf("cannot infer endpoint between %s and %s: %v", epPair[0], epPair[1], err)
+				verifier.addInferenceError(path, epPair[0], epPair[1], err)
 			} else {
 				// Change the endpoints that get recorded
 				// as seen, so we'll diagnose a duplicate
@@ -629,12 +849,12 @@ func (verifier *bundleDataVerifier) verifyRelations() {
 			epPair[1], epPair[0] = epPair[0], epPair[1]
 		}
 		if _, ok := seen[epPair]; ok {
-			verifier.addErrorf("relation %q is defined more than once", relPair)
+			verifier.addErrorf("duplicate-relation", path, "relation %q is defined more than once", relPair)
 		}
 		if verifier.charms != nil && epPair[0].relation != "" && epPair[1].relation != "" {
 			// We have charms to verify against, and the
 			// endpoint has been fully specified or inferred.
-			verifier.verifyRelation(epPair[0], epPair[1])
+			verifier.verifyRelation(path, epPair[0], epPair[1])
 		}
 		seen[epPair] = true
 	}
@@ -654,7 +874,7 @@ func (verifier *bundleDataVerifier) verifyEndpointBindings() {
 			_, isInExtraBindings := charm.Meta().ExtraBindings[endpoint]
 
 			if !(isInProvides || isInRequires || isInPeers || isInExtraBindings) {
-				verifier.addErrorf(
+				verifier.addErrorf("unknown-endpoint", "applications."+name+".bindings."+endpoint,
 					"application %q wants to bind endpoint %q to space %q, "+
 						"but the endpoint is not defined by the charm",
 					name, endpoint, space)
@@ -676,7 +896,7 @@ var infoRelation = Relation{
 // defined, and that the relationship is correctly
 // symmetrical (provider to requirer) and shares
 // the same interface.
-func (verifier *bundleDataVerifier) verifyRelation(ep0, ep1 endpoint) {
+func (verifier *bundleDataVerifier) verifyRelation(path string, ep0, ep1 endpoint) {
 	svc0 := verifier.bd.Applications[ep0.application]
 	svc1 := verifier.bd.Applications[ep1.application]
 	if svc0 == nil || svc1 == nil || svc0 == svc1 {
@@ -697,7 +917,7 @@ func (verifier *bundleDataVerifier) verifyRelation(ep0, ep1 endpoint) {
 	}
 	relReq0, okReq0 := charm0.Meta().Requires[ep0.relation]
 	if !okProv0 && !okReq0 {
-		verifier.addErrorf("charm %q used by application %q does not define relation %q", svc0.Charm, ep0.application, ep0.relation)
+		verifier.addErrorf("unknown-endpoint", path, "charm %q used by application %q does not define relation %q", svc0.Charm, ep0.application, ep0.relation)
 	}
 	relProv1, okProv1 := charm1.Meta().Provides[ep1.relation]
 	// The juju-info relation is provided implicitly by every
@@ -707,7 +927,7 @@ func (verifier *bundleDataVerifier) verifyRelation(ep0, ep1 endpoint) {
 	}
 	relReq1, okReq1 := charm1.Meta().Requires[ep1.relation]
 	if !okProv1 && !okReq1 {
-		verifier.addErrorf("charm %q used by application %q does not define relation %q", svc1.Charm, ep1.application, ep1.relation)
+		verifier.addErrorf("unknown-endpoint", path, "charm %q used by application %q does not define relation %q", svc1.Charm, ep1.application, ep1.relation)
 	}
 
 	var relProv, relReq Relation
@@ -720,17 +940,20 @@ func (verifier *bundleDataVerifier) verifyRelation(ep0, ep1 endpoint) {
 		relProv, relReq = relProv1, relReq0
 		epProv, epReq = ep1, ep0
 	case okProv0 && okProv1:
-		verifier.addErrorf("relation %q to %q relates provider to provider", ep0, ep1)
+		verifier.addErrorf("role-mismatch", path, "relation %q to %q relates provider to provider", ep0, ep1)
 		return
 	case okReq0 && okReq1:
-		verifier.addErrorf("relation %q to %q relates requirer to requirer", ep0, ep1)
+		verifier.addErrorf("role-mismatch", path, "relation %q to %q relates requirer to requirer", ep0, ep1)
 		return
 	default:
 		// Errors were added above.
 		return
 	}
 	if relProv.Interface != relReq.Interface {
-		verifier.addErrorf("mismatched interface between %q and %q (%q vs %q)", epProv, epReq, relProv.Interface, relReq.Interface)
+		verifier.addErrorf("interface-mismatch", path, "mismatched interface between %q and %q (%q vs %q)", epProv, epReq, relProv.Interface, relReq.Interface)
+	} else if !relProv.Versions.Compatible(relReq.Versions) {
+		verifier.addErrorf("version-mismatch", path, "incompatible interface versions between %q and %q (%d-%d vs %d-%d)", epProv, epReq,
+			relProv.Versions.Min, relProv.Versions.Max, relReq.Versions.Min, relReq.Versions.Max)
 	}
 }
 
@@ -750,12 +973,12 @@ func (verifier *bundleDataVerifier) verifyOptions() {
 		for name, value := range svc.Options {
 			opt, ok := config.Options[name]
 			if !ok {
-				verifier.addErrorf("cannot validate application %q: configuration option %q not found in charm %q", appName, name, svc.Charm)
+				verifier.addErrorf("unknown-option", "applications."+appName+".options."+name, "cannot validate application %q: configuration option %q not found in charm %q", appName, name, svc.Charm)
 				continue
 			}
 			_, err := opt.validate(name, value)
 			if err != nil {
-				verifier.addErrorf("cannot validate application %q: %v", appName, err)
+				verifier.addErrorf("invalid-option", "applications."+appName+".options."+name, "cannot validate application %q: %v", appName, err)
 			}
 		}
 	}
@@ -813,7 +1036,7 @@ func (ep endpointInfo) String() string {
 // and other.
 func (ep endpointInfo) canRelateTo(other endpointInfo) bool {
 	return ep.applicationName != other.applicationName &&
-		ep.Interface == other.Interface &&
+		ep.Relation.CanRelateTo(other.Relation) &&
 		ep.Role != RolePeer &&
 		counterpartRole(ep.Role) == other.Role
 }
@@ -931,7 +1154,7 @@ func inferEndpoints(epSpec0, epSpec1 endpoint, get func(svc string) (*Meta, erro
 	}
 	switch len(candidates) {
 	case 0:
-		return endpoint{}, endpoint{}, fmt.Errorf("no relations found")
+		return endpoint{}, endpoint{}, inferenceErrorf("invalid-relation", "no relations found")
 	case 1:
 		return candidates[0][0].endpoint(), candidates[0][1].endpoint(), nil
 	}
@@ -947,7 +1170,7 @@ func inferEndpoints(epSpec0, epSpec1 endpoint, get func(svc string) (*Meta, erro
 		keys = append(keys, fmt.Sprintf("%q", relationKey(cand)))
 	}
 	sort.Strings(keys)
-	return endpoint{}, endpoint{}, fmt.Errorf("ambiguous relation: %s %s could refer to %s",
+	return endpoint{}, endpoint{}, inferenceErrorf("ambiguous-relation", "ambiguous relation: %s %s could refer to %s",
 		epSpec0, epSpec1, strings.Join(keys, "; "))
 }
 
@@ -4,6 +4,7 @@
 package charm_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -214,6 +215,57 @@ relations:
     - ["wordpress:db", "mysql:db"]
 `,
 	expectedErr: ".*cannot specify both applications and services",
+}, {
+	about: "anchors and merge keys",
+	data: `
+applications:
+    mysql:
+        charm: cs:mysql
+        num_units: 1
+        options: &db-options
+            flavor: percona
+            dataset-size: 50%
+        constraints: &small mem=2G
+    mysql-slave:
+        <<: &db
+            charm: cs:mysql
+            options: *db-options
+        num_units: 2
+        constraints: *small
+    mysql-backup:
+        <<: *db
+        constraints: *small
+`,
+	expectedBD: &charm.BundleData{
+		Applications: map[string]*charm.ApplicationSpec{
+			"mysql": {
+				Charm:    "cs:mysql",
+				NumUnits: 1,
+				Options: map[string]interface{}{
+					"flavor":       "percona",
+					"dataset-size": "50%",
+				},
+				Constraints: "mem=2G",
+			},
+			"mysql-slave": {
+				Charm:    "cs:mysql",
+				NumUnits: 2,
+				Options: map[string]interface{}{
+					"flavor":       "percona",
+					"dataset-size": "50%",
+				},
+				Constraints: "mem=2G",
+			},
+			"mysql-backup": {
+				Charm: "cs:mysql",
+				Options: map[string]interface{}{
+					"flavor":       "percona",
+					"dataset-size": "50%",
+				},
+				Constraints: "mem=2G",
+			},
+		},
+	},
 }}
 
 func (*bundleDataSuite) TestParse(c *gc.C) {
@@ -231,6 +283,93 @@ func (*bundleDataSuite) TestParse(c *gc.C) {
 	}
 }
 
+// billionLaughs expands to more than a billion nodes.
+const billionLaughs = `
+a: &a ["lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol", "lol"]
+b: &b [*a, *a, *a, *a, *a, *a, *a, *a, *a]
+c: &c [*b, *b, *b, *b, *b, *b, *b, *b, *b]
+d: &d [*c, *c, *c, *c, *c, *c, *c, *c, *c]
+e: &e [*d, *d, *d, *d, *d, *d, *d, *d, *d]
+f: &f [*e, *e, *e, *e, *e, *e, *e, *e, *e]
+g: &g [*f, *f, *f, *f, *f, *f, *f, *f, *f]
+h: &h [*g, *g, *g, *g, *g, *g, *g, *g, *g]
+i: &i [*h, *h, *h, *h, *h, *h, *h, *h, *h]
+applications: *i
+`
+
+func (*bundleDataSuite) TestParseTooLarge(c *gc.C) {
+	_, err := charm.ReadBundleData(strings.NewReader(billionLaughs))
+	c.Assert(err, gc.ErrorMatches, `bundle data too large: more than 100000 YAML nodes after expanding aliases`)
+}
+
+func (*bundleDataSuite) TestParseRecursiveAlias(c *gc.C) {
+	for i, data := range []string{`
+applications:
+    wordpress: &wp
+        charm: wordpress
+        options:
+            self: *wp
+`, `
+applications: &apps [*apps]
+`, `
+applications:
+    wordpress:
+        charm: wordpress
+        options:
+            a: &a
+                b: [&b [*a, *b]]
+`} {
+		c.Logf("test %d", i)
+		for _, limits := range []charm.BundleLimits{{}, charm.DefaultBundleLimits()} {
+			_, _, err := charm.ReadBundleDataWithLimits(strings.NewReader(data), limits)
+			c.Assert(err, gc.ErrorMatches, `cannot expand bundle data: recursive alias: anchor '.*' value contains itself`)
+		}
+	}
+}
+
+func (*bundleDataSuite) TestDefaultBundleLimits(c *gc.C) {
+	limits := charm.DefaultBundleLimits()
+	limits.MaxNodes = 1
+	c.Assert(charm.DefaultBundleLimits().MaxNodes, gc.Equals, 100000)
+}
+
+func (*bundleDataSuite) TestParseEncoding(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader("\ufeff" + mediawikiBundle))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Applications, gc.HasLen, 2)
+
+	_, err = charm.ReadBundleData(strings.NewReader("series: \x80\n"))
+	c.Assert(err, gc.ErrorMatches, `bundle.yaml: invalid UTF-8 at byte offset 8 .*`)
+	c.Assert(charm.IsEncodingError(err), jc.IsTrue)
+}
+
+func (*bundleDataSuite) TestParseWithLimits(c *gc.C) {
+	data := `
+applications:
+    wordpress: &wp
+        charm: wordpress
+    blog: *wp
+`
+	bd, size, err := charm.ReadBundleDataWithLimits(strings.NewReader(data), charm.BundleLimits{})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Applications, gc.HasLen, 2)
+	// The top level map and its key, the applications map and
+	// its two keys, and two copies of the wordpress application,
+	// each with a key and value.
+	c.Assert(size, gc.Equals, 11)
+
+	bd, size, err = charm.ReadBundleDataWithLimits(strings.NewReader(data), charm.BundleLimits{
+		MaxNodes: 11,
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(size, gc.Equals, 11)
+
+	_, _, err = charm.ReadBundleDataWithLimits(strings.NewReader(data), charm.BundleLimits{
+		MaxNodes: 10,
+	})
+	c.Assert(err, gc.ErrorMatches, `bundle data too large: more than 10 YAML nodes after expanding aliases`)
+}
+
 func (*bundleDataSuite) TestCodecRoundTrip(c *gc.C) {
 	for _, test := range parseTests {
 		if test.expectedErr != "" {
@@ -473,6 +612,87 @@ func assertVerifyErrors(c *gc.C, bundleData string, charms map[string]charm.Char
 	c.Assert(errStrings, jc.DeepEquals, expectErrors)
 }
 
+func (*bundleDataSuite) TestVerificationReport(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+series: "9wrong"
+machines:
+    0:
+        constraints: bad constraints
+    1:
+applications:
+    mysql:
+        charm: "bogus:precise/mysql"
+        num_units: -4
+        to: [0, "foo/0"]
+relations:
+    - ["mysql:db", "mediawiki:db"]
+`))
+	c.Assert(err, jc.ErrorIsNil)
+	err = bd.Verify(func(c string) error {
+		if c == "bad constraints" {
+			return fmt.Errorf("bad constraint")
+		}
+		return nil
+	}, nil, nil)
+	c.Assert(err, gc.FitsTypeOf, (*charm.VerificationError)(nil))
+	report := err.(*charm.VerificationError).Report()
+	for _, findings := range report.Applications {
+		sort.Slice(findings, func(i, j int) bool {
+			return findings[i].Path < findings[j].Path
+		})
+	}
+	for _, findings := range report.Machines {
+		sort.Slice(findings, func(i, j int) bool {
+			return findings[i].Path < findings[j].Path
+		})
+	}
+	c.Assert(report, jc.DeepEquals, &charm.VerificationReport{
+		Bundle: []*charm.VerificationFinding{{
+			Code:    "invalid-series",
+			Path:    "series",
+			Message: `bundle declares an invalid series "9wrong"`,
+		}},
+		Applications: map[string][]*charm.VerificationFinding{
+			"mysql": {{
+				Code:    "invalid-charm",
+				Path:    "applications.mysql.charm",
+				Message: `invalid charm URL in application "mysql": cannot parse URL "bogus:precise/mysql": schema "bogus" not valid`,
+			}, {
+				Code:    "invalid-num-units",
+				Path:    "applications.mysql.num_units",
+				Message: `negative number of units specified on application "mysql"`,
+			}, {
+				Code:    "unknown-application",
+				Path:    "applications.mysql.to.1",
+				Message: `placement "foo/0" refers to an application not defined in this bundle`,
+			}},
+		},
+		Machines: map[string][]*charm.VerificationFinding{
+			"0": {{
+				Code:    "invalid-constraints",
+				Path:    "machines.0.constraints",
+				Message: `invalid constraints "bad constraints" in machine "0": bad constraint`,
+			}},
+			"1": {{
+				Code:    "unused-machine",
+				Path:    "machines.1",
+				Message: `machine "1" is not referred to by a placement directive`,
+			}},
+		},
+		Relations: map[int][]*charm.VerificationFinding{
+			0: {{
+				Code:    "unknown-application",
+				Path:    "relations.0",
+				Message: `relation ["mysql:db" "mediawiki:db"] refers to application "mediawiki" not defined in this bundle`,
+			}},
+		},
+	})
+
+	data, err := json.Marshal(report.Relations)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, `{"0":[{"code":"unknown-application","path":"relations.0","message":"relation [\"mysql:db\" \"mediawiki:db\"] refers to application \"mediawiki\" not defined in this bundle"}]}`)
+}
+
 func (*bundleDataSuite) TestVerifyCharmURL(c *gc.C) {
 	bd, err := charm.ReadBundleData(strings.NewReader(mediawikiBundle))
 	c.Assert(err, gc.IsNil)
@@ -575,6 +795,123 @@ func (*bundleDataSuite) TestRequiredCharms(c *gc.C) {
 	c.Assert(reqCharms, gc.DeepEquals, []string{"cs:precise/mediawiki-10", "cs:precise/mysql-28"})
 }
 
+func (*bundleDataSuite) TestReferencedCharms(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    blog:
+        charm: cs:wordpress
+    db:
+        charm: cs:mysql
+    wordpress:
+        charm: cs:wordpress
+    local:
+        charm: ./charms/dummy
+    replica:
+        charm: mysql
+`))
+	c.Assert(err, gc.IsNil)
+	c.Assert(bd.ReferencedCharms(), jc.DeepEquals, []charm.CharmReference{{
+		Charm: "./charms/dummy",
+		Paths: []string{"applications.local.charm"},
+	}, {
+		Charm: "cs:mysql",
+		Paths: []string{"applications.db.charm", "applications.replica.charm"},
+	}, {
+		Charm: "cs:wordpress",
+		Paths: []string{"applications.blog.charm", "applications.wordpress.charm"},
+	}})
+}
+
+func (*bundleDataSuite) TestNormalizeRelations(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: wordpress
+    mysql:
+        charm: mysql
+    logging:
+        charm: logging-sub
+    monitoring:
+        charm: monitoring
+relations:
+    - ["wordpress:db", "mysql:server"]
+    - ["mysql:server", "wordpress:db"]
+    - ["wordpress", "mysql"]
+    - ["wordpress", "logging"]
+    - ["wordpress", "monitoring"]
+    - ["logging:logging-client", "mysql"]
+    - ["bad endpoint", "mysql"]
+`))
+	c.Assert(err, gc.IsNil)
+
+	// Without charms, relations are only ordered and deduplicated.
+	bd1 := *bd
+	err = bd1.NormalizeRelations(nil)
+	c.Assert(err, gc.ErrorMatches, `invalid relation syntax "bad endpoint"`)
+	c.Assert(bd1.Relations, jc.DeepEquals, [][]string{
+		{"logging", "wordpress"},
+		{"logging:logging-client", "mysql"},
+		{"monitoring", "wordpress"},
+		{"mysql", "wordpress"},
+		{"mysql:server", "wordpress:db"},
+		{"bad endpoint", "mysql"},
+	})
+
+	charms := map[string]charm.Charm{
+		"wordpress":   testCharm("wordpress", "web:http | db:mysql logging:logging monitor:monitoring monitor2:monitoring"),
+		"mysql":       testCharm("mysql", "server:mysql | logging:logging"),
+		"logging-sub": testCharm("logging-sub", "logging-client:logging | info:juju-info"),
+		"monitoring":  testCharm("monitoring", "monitoring:monitoring"),
+	}
+	err = bd.NormalizeRelations(charms)
+	c.Assert(err, gc.FitsTypeOf, &charm.VerificationError{})
+	findings := err.(*charm.VerificationError).Errors
+	c.Assert(findings, gc.HasLen, 2)
+	c.Assert(findings[0], jc.DeepEquals, &charm.VerificationFinding{
+		Code:    "ambiguous-relation",
+		Path:    "relations.4",
+		Message: `cannot infer endpoint between wordpress and monitoring: ambiguous relation: wordpress monitoring could refer to "monitoring:monitoring wordpress:monitor"; "monitoring:monitoring wordpress:monitor2"`,
+	})
+	c.Assert(findings[1].(*charm.VerificationFinding).Code, gc.Equals, "invalid-relation")
+	c.Assert(bd.Relations, jc.DeepEquals, [][]string{
+		{"logging:logging-client", "mysql:logging"},
+		{"logging:logging-client", "wordpress:logging"},
+		{"monitoring", "wordpress"},
+		{"mysql:server", "wordpress:db"},
+		{"bad endpoint", "mysql"},
+	})
+}
+
+func (*bundleDataSuite) TestNormalizeRelationsUnknownApplication(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(`
+applications:
+    wordpress:
+        charm: wordpress
+    mysql:
+        charm: mysql
+relations:
+    - ["wordpress", "mysql"]
+    - ["wordpress", "mongodb"]
+`))
+	c.Assert(err, gc.IsNil)
+	err = bd.NormalizeRelations(map[string]charm.Charm{
+		"wordpress": testCharm("wordpress", "| db:mysql"),
+	})
+	c.Assert(err, gc.FitsTypeOf, &charm.VerificationError{})
+	c.Assert(err.(*charm.VerificationError).Errors, jc.DeepEquals, []error{
+		&charm.VerificationFinding{
+			Code:    "charm-not-found",
+			Path:    "relations.0",
+			Message: `cannot infer endpoint between wordpress and mysql: charm "mysql" from application "mysql" not found`,
+		},
+		&charm.VerificationFinding{
+			Code:    "unknown-application",
+			Path:    "relations.1",
+			Message: `cannot infer endpoint between wordpress and mongodb: application "mongodb" not found`,
+		},
+	})
+}
+
 // testCharm returns a charm with the given name
 // and relations. The relations are specified as
 // a string of the form:
@@ -0,0 +1,90 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"sort"
+)
+
+// NormalizeRelations rewrites the bundle's relations in a canonical
+// form. The endpoints of each relation are put in order, the relations
+// are sorted and any duplicate relations are removed.
+//
+// If charms is not nil, it should hold an entry for each charm URL
+// returned by RequiredCharms, as for VerifyWithCharms. Endpoints
+// that omit the relation name are then filled in when there is only
+// one relation that they could refer to.
+//
+// Relations that cannot be parsed are kept unchanged after the others,
+// and endpoints whose relation names cannot be inferred are left in
+// their short form. Both are reported in the returned error, which is
+// a *VerificationError.
+func (bd *BundleData) NormalizeRelations(charms map[string]Charm) error {
+	if len(bd.Relations) == 0 {
+		return nil
+	}
+	verifier := &bundleDataVerifier{
+		bd:     bd,
+		charms: charms,
+	}
+	var pairs endpointPairs
+	var invalid [][]string
+	seen := make(map[[2]endpoint]bool)
+	for i, relPair := range bd.Relations {
+		path := fmt.Sprintf("relations.%d", i)
+		if len(relPair) != 2 {
+			verifier.addErrorf("invalid-relation", path, "relation %q has %d endpoint(s), not 2", relPair, len(relPair))
+			invalid = append(invalid, relPair)
+			continue
+		}
+		ep0, err0 := parseEndpoint(relPair[0])
+		ep1, err1 := parseEndpoint(relPair[1])
+		if err0 != nil || err1 != nil {
+			if err0 == nil {
+				err0 = err1
+			}
+			verifier.addErrorf("invalid-relation", path, "%v", err0)
+			invalid = append(invalid, relPair)
+			continue
+		}
+		if (ep0.relation == "" || ep1.relation == "") && charms != nil {
+			iep0, iep1, err := inferEndpoints(ep0, ep1, verifier.getCharmMetaForApplication)
+			if err != nil {
+				verifier.addInferenceError(path, ep0, ep1, err)
+			} else {
+				ep0, ep1 = iep0, iep1
+			}
+		}
+		pair := [2]endpoint{ep0, ep1}
+		if ep1.less(ep0) {
+			pair = [2]endpoint{ep1, ep0}
+		}
+		if seen[pair] {
+			continue
+		}
+		seen[pair] = true
+		pairs = append(pairs, pair)
+	}
+	sort.Sort(pairs)
+	relations := make([][]string, 0, len(pairs)+len(invalid))
+	for _, pair := range pairs {
+		relations = append(relations, []string{pair[0].String(), pair[1].String()})
+	}
+	bd.Relations = append(relations, invalid...)
+	return verifier.err()
+}
+
+// endpointPairs implements sort.Interface to sort relations
+// by their first endpoint and then by their second.
+type endpointPairs [][2]endpoint
+
+func (p endpointPairs) Len() int      { return len(p) }
+func (p endpointPairs) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+func (p endpointPairs) Less(i, j int) bool {
+	if p[i][0] != p[j][0] {
+		return p[i][0].less(p[j][0])
+	}
+	return p[i][1].less(p[j][1])
+}
@@ -0,0 +1,206 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	validBundleVariable     = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_-]*$`)
+	bundleVariableReference = regexp.MustCompile(`\$?\$\{([^}]*)\}`)
+)
+
+// ReadBundleDataWithVariables is like ReadBundleData except that it
+// also substitutes the bundle's variables, taking their values from
+// values where present and from the bundle's defaults otherwise.
+// See BundleData.SubstituteVariables for details.
+func ReadBundleDataWithVariables(r io.Reader, values map[string]string) (*BundleData, error) {
+	bd, err := ReadBundleData(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := bd.SubstituteVariables(values); err != nil {
+		return nil, err
+	}
+	return bd, nil
+}
+
+// SubstituteVariables replaces each ${name} reference in the
+// application options and in the application and machine constraints
+// with the value of the named variable. The variables must be
+// declared in the bundle's variables section, which also holds their
+// default values; values holds the values supplied by the caller,
+// which take precedence over the defaults.
+//
+// Only options with string values are substituted; other values,
+// including lists and maps, are left as they are. A literal "${"
+// may be written as "$${".
+//
+// If the bundle declares no variables, the bundle data is left
+// unchanged, so bundles written before variables existed keep their
+// option values as written.
+//
+// It is an error for values to hold a variable that the bundle does
+// not declare, for the bundle to refer to an undeclared variable
+// or for a declared variable to be unused. If there is an error,
+// the bundle data is left unchanged. Otherwise Variables is set
+// to nil, as the bundle no longer refers to any variables.
+func (bd *BundleData) SubstituteVariables(values map[string]string) error {
+	for _, name := range sortedVariableNames(bd.Variables) {
+		if !validBundleVariable.MatchString(name) {
+			return fmt.Errorf("invalid bundle variable name %q", name)
+		}
+	}
+	for _, name := range sortedVariableNames(values) {
+		if _, ok := bd.Variables[name]; !ok {
+			return fmt.Errorf("value supplied for undeclared bundle variable %q", name)
+		}
+	}
+	if len(bd.Variables) == 0 {
+		return nil
+	}
+	s := &variableSubstituter{
+		values: make(map[string]string),
+		used:   make(map[string]bool),
+	}
+	for name, value := range bd.Variables {
+		s.values[name] = value
+	}
+	for name, value := range values {
+		s.values[name] = value
+	}
+
+	// Substitute into copies first so that the bundle data
+	// is not changed if there's an error.
+	applications := make(map[string]*ApplicationSpec)
+	for _, appName := range sortedApplicationNames(bd.Applications) {
+		app := bd.Applications[appName]
+		if app == nil {
+			continue
+		}
+		newApp := *app
+		where := fmt.Sprintf("application %q constraints", appName)
+		var err error
+		if newApp.Constraints, err = s.substitute(where, app.Constraints); err != nil {
+			return err
+		}
+		if app.Options != nil {
+			newApp.Options = make(map[string]interface{})
+			for _, name := range sortedOptionNames(app.Options) {
+				value := app.Options[name]
+				if str, ok := value.(string); ok {
+					where := fmt.Sprintf("application %q option %q", appName, name)
+					if value, err = s.substitute(where, str); err != nil {
+						return err
+					}
+				}
+				newApp.Options[name] = value
+			}
+		}
+		applications[appName] = &newApp
+	}
+	machines := make(map[string]*MachineSpec)
+	for _, id := range sortedMachineIds(bd.Machines) {
+		m := bd.Machines[id]
+		if m == nil {
+			continue
+		}
+		newMachine := *m
+		where := fmt.Sprintf("machine %q constraints", id)
+		var err error
+		if newMachine.Constraints, err = s.substitute(where, m.Constraints); err != nil {
+			return err
+		}
+		machines[id] = &newMachine
+	}
+	for _, name := range sortedVariableNames(bd.Variables) {
+		if !s.used[name] {
+			return fmt.Errorf("bundle variable %q is not used", name)
+		}
+	}
+
+	for appName, app := range applications {
+		bd.Applications[appName] = app
+	}
+	for id, m := range machines {
+		bd.Machines[id] = m
+	}
+	bd.Variables = nil
+	return nil
+}
+
+// variableSubstituter substitutes bundle variable references
+// in strings, recording which variables are used.
+type variableSubstituter struct {
+	values map[string]string
+	used   map[string]bool
+}
+
+// substitute returns s with all variable references replaced
+// and all escaped references ("$${") unescaped.
+// The where argument describes the location of s in the bundle
+// and is used in error messages.
+func (vs *variableSubstituter) substitute(where, s string) (string, error) {
+	var err error
+	result := bundleVariableReference.ReplaceAllStringFunc(s, func(ref string) string {
+		if strings.HasPrefix(ref, "$$") {
+			return ref[1:]
+		}
+		name := ref[len("${") : len(ref)-len("}")]
+		value, ok := vs.values[name]
+		if !ok {
+			if err == nil {
+				err = fmt.Errorf("%s: undefined bundle variable %q", where, name)
+			}
+			return ref
+		}
+		vs.used[name] = true
+		return value
+	})
+	if err != nil {
+		return "", err
+	}
+	return result, nil
+}
+
+func sortedVariableNames(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedApplicationNames(m map[string]*ApplicationSpec) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedMachineIds(m map[string]*MachineSpec) []string {
+	ids := make([]string, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func sortedOptionNames(m map[string]interface{}) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
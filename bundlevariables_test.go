@@ -0,0 +1,182 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type bundleVariablesSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&bundleVariablesSuite{})
+
+const variablesBundle = `
+variables:
+    title: My Blog
+    mem: 4G
+applications:
+    wordpress:
+        charm: cs:wordpress
+        num_units: 1
+        constraints: mem=${mem}
+        options:
+            blog-title: "${title} (${mem})"
+            port: 80
+    mysql:
+        charm: cs:mysql
+        num_units: 1
+        to: ["0"]
+machines:
+    "0":
+        constraints: mem=${mem} cores=2
+`
+
+func (*bundleVariablesSuite) TestReadBundleDataWithVariables(c *gc.C) {
+	bd, err := charm.ReadBundleDataWithVariables(strings.NewReader(variablesBundle), map[string]string{
+		"mem": "8G",
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Variables, gc.IsNil)
+	c.Assert(bd.Applications["wordpress"].Constraints, gc.Equals, "mem=8G")
+	c.Assert(bd.Applications["wordpress"].Options, jc.DeepEquals, map[string]interface{}{
+		"blog-title": "My Blog (8G)",
+		"port":       80,
+	})
+	c.Assert(bd.Machines["0"].Constraints, gc.Equals, "mem=8G cores=2")
+}
+
+func (*bundleVariablesSuite) TestReadBundleDataKeepsReferences(c *gc.C) {
+	bd, err := charm.ReadBundleData(strings.NewReader(variablesBundle))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Variables, jc.DeepEquals, map[string]string{
+		"title": "My Blog",
+		"mem":   "4G",
+	})
+	c.Assert(bd.Applications["wordpress"].Constraints, gc.Equals, "mem=${mem}")
+
+	err = bd.SubstituteVariables(nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Applications["wordpress"].Constraints, gc.Equals, "mem=4G")
+	c.Assert(bd.Applications["wordpress"].Options["blog-title"], gc.Equals, "My Blog (4G)")
+}
+
+var substituteVariablesErrorTests = []struct {
+	about       string
+	data        string
+	values      map[string]string
+	expectError string
+}{{
+	about:       "undeclared value",
+	data:        variablesBundle,
+	values:      map[string]string{"colour": "blue"},
+	expectError: `value supplied for undeclared bundle variable "colour"`,
+}, {
+	about: "undefined reference",
+	data: `
+variables:
+    mem: 4G
+applications:
+    wordpress:
+        charm: cs:wordpress
+        constraints: mem=${mem}
+        options:
+            blog-title: ${title}
+`,
+	expectError: `application "wordpress" option "blog-title": undefined bundle variable "title"`,
+}, {
+	about: "unused variable",
+	data: `
+variables:
+    title: My Blog
+applications:
+    wordpress:
+        charm: cs:wordpress
+`,
+	expectError: `bundle variable "title" is not used`,
+}, {
+	about: "invalid name",
+	data: `
+variables:
+    "bad name": x
+applications:
+    wordpress:
+        charm: cs:wordpress
+`,
+	expectError: `invalid bundle variable name "bad name"`,
+}}
+
+func (*bundleVariablesSuite) TestSubstituteVariablesErrors(c *gc.C) {
+	for i, test := range substituteVariablesErrorTests {
+		c.Logf("test %d: %s", i, test.about)
+		bd, err := charm.ReadBundleData(strings.NewReader(test.data))
+		c.Assert(err, jc.ErrorIsNil)
+		before := *bd.Applications["wordpress"]
+		err = bd.SubstituteVariables(test.values)
+		c.Check(err, gc.ErrorMatches, test.expectError)
+		c.Check(*bd.Applications["wordpress"], jc.DeepEquals, before)
+	}
+}
+
+func (*bundleVariablesSuite) TestSubstituteVariablesEscape(c *gc.C) {
+	bd, err := charm.ReadBundleDataWithVariables(strings.NewReader(`
+variables:
+    title: My Blog
+applications:
+    wordpress:
+        charm: cs:wordpress
+        options:
+            blog-title: "${title}"
+            template: "$${title} ${"
+`), nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Applications["wordpress"].Options, jc.DeepEquals, map[string]interface{}{
+		"blog-title": "My Blog",
+		"template":   "${title} ${",
+	})
+}
+
+func (*bundleVariablesSuite) TestSubstituteVariablesWithoutVariables(c *gc.C) {
+	data := `
+applications:
+    wordpress:
+        charm: cs:wordpress
+        constraints: mem=${mem}
+        options:
+            template: "${title} $${title}"
+            names: ["${title}"]
+`
+	bd, err := charm.ReadBundleDataWithVariables(strings.NewReader(data), nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Applications["wordpress"].Constraints, gc.Equals, "mem=${mem}")
+	c.Assert(bd.Applications["wordpress"].Options, jc.DeepEquals, map[string]interface{}{
+		"template": "${title} $${title}",
+		"names":    []interface{}{"${title}"},
+	})
+}
+
+func (*bundleVariablesSuite) TestSubstituteVariablesOnlyStrings(c *gc.C) {
+	bd, err := charm.ReadBundleDataWithVariables(strings.NewReader(`
+variables:
+    title: My Blog
+applications:
+    wordpress:
+        charm: cs:wordpress
+        options:
+            blog-title: "${title}"
+            names: ["${title}"]
+`), nil)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bd.Applications["wordpress"].Options, jc.DeepEquals, map[string]interface{}{
+		"blog-title": "My Blog",
+		"names":      []interface{}{"${title}"},
+	})
+}
@@ -12,6 +12,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 
 	"github.com/juju/collections/set"
@@ -268,13 +269,21 @@ func (a *CharmArchive) Manifest() (set.Strings, error) {
 
 // ExpandTo expands the charm archive into dir, creating it if necessary.
 // If any errors occur during the expansion procedure, the process will
-// abort.
+// abort. Archives holding symlinks cannot be expanded on platforms
+// without symlink support, such as Windows.
 func (a *CharmArchive) ExpandTo(dir string) error {
 	zipr, err := a.zopen.openZip()
 	if err != nil {
 		return err
 	}
 	defer zipr.Close()
+	if !symlinksSupported {
+		for _, f := range zipr.File {
+			if f.Mode()&os.ModeSymlink != 0 {
+				return fmt.Errorf("cannot expand charm: %q is a symlink, which is not supported on this platform", f.Name)
+			}
+		}
+	}
 	if err := ziputil.ExtractAll(zipr.Reader, dir); err != nil {
 		return err
 	}
@@ -301,6 +310,10 @@ func (a *CharmArchive) ExpandTo(dir string) error {
 	return nil
 }
 
+// symlinksSupported holds whether symlinks can be created
+// when a charm archive is expanded.
+var symlinksSupported = runtime.GOOS != "windows"
+
 // fixHookFunc returns a WalkFunc that makes sure hooks are owner-executable.
 func fixHookFunc(hooksDir string, hookNames map[string]bool) filepath.WalkFunc {
 	return func(path string, info os.FileInfo, err error) error {
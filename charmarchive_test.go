@@ -15,6 +15,7 @@ import (
 	"syscall"
 
 	"github.com/juju/collections/set"
+	"github.com/juju/testing"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 	"gopkg.in/yaml.v2"
@@ -357,6 +358,21 @@ func (s *CharmArchiveSuite) TestExpandToWithBadLink(c *gc.C) {
 	c.Assert(err, gc.ErrorMatches, `cannot extract "hooks/badlink": symlink "/target" is absolute`)
 }
 
+func (s *CharmArchiveSuite) TestExpandToWithoutSymlinkSupport(c *gc.C) {
+	srcPath := cloneDir(c, charmDirPath(c, "dummy"))
+	if err := os.Symlink("../target", filepath.Join(srcPath, "hooks/symlink")); err != nil {
+		c.Skip("cannot symlink")
+	}
+	archive := archiveDir(c, srcPath)
+	defer testing.PatchValue(charm.SymlinksSupported, false).Restore()
+
+	path := filepath.Join(c.MkDir(), "charm")
+	err := archive.ExpandTo(path)
+	c.Assert(err, gc.ErrorMatches, `cannot expand charm: "hooks/symlink" is a symlink, which is not supported on this platform`)
+	_, err = os.Stat(path)
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+}
+
 func extCharmArchiveDirPath(c *gc.C, dirpath string) string {
 	path := filepath.Join(c.MkDir(), "archive.charm")
 	cmd := exec.Command("/bin/sh", "-c", fmt.Sprintf("cd %s; zip --fifo --symlinks -r %s .", dirpath, path))
@@ -10,6 +10,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
 	"syscall"
@@ -173,15 +174,34 @@ func resolveSymlinkedRoot(rootPath string) (string, error) {
 
 // ArchiveTo creates a charm file from the charm expanded in dir.
 // By convention a charm archive should have a ".charm" suffix.
+//
+// The name blobs.yaml at the charm root is reserved for the
+// manifest written by ArchiveThinTo; a file of that name in dir
+// is never archived.
 func (dir *CharmDir) ArchiveTo(w io.Writer) error {
 	versionString, err := dir.MaybeGenerateVersionString()
 	if err != nil {
 		logger.Warningf("version string generation failed : %v", err)
 	}
-	return writeArchive(w, dir.Path, dir.revision, versionString, dir.Meta().Hooks())
+	return writeArchiveExcluding(w, dir.Path, dir.revision, versionString, dir.Meta().Hooks(), attachedResourcePaths(dir.Path, dir.meta), nil)
 }
 
 func writeArchive(w io.Writer, path string, revision int, versionString string, hooks map[string]bool) error {
+	return writeArchiveExcluding(w, path, revision, versionString, hooks, nil, nil)
+}
+
+// writeArchiveExcluding is like writeArchive except that files whose
+// slash-separated relative paths are in exclude are left out of the
+// archive, and the contents of extra are added to it, keyed by path.
+func writeArchiveExcluding(
+	w io.Writer,
+	path string,
+	revision int,
+	versionString string,
+	hooks map[string]bool,
+	exclude map[string]bool,
+	extra map[string]string,
+) error {
 	zipw := zip.NewWriter(w)
 	defer zipw.Close()
 
@@ -191,20 +211,32 @@ func writeArchive(w io.Writer, path string, revision int, versionString string,
 	if err != nil {
 		return err
 	}
-	zp := zipPacker{zipw, rootPath, hooks}
+	zp := zipPacker{zipw, rootPath, hooks, exclude}
 	if revision != -1 {
 		zp.AddFile("revision", strconv.Itoa(revision))
 	}
 	if versionString != "" {
 		zp.AddFile("version", versionString)
 	}
+	for name, content := range extra {
+		if err := zp.AddFile(name, content); err != nil {
+			return err
+		}
+	}
 	return filepath.Walk(rootPath, zp.WalkFunc())
 }
 
+// posixModes holds whether file modes on this platform hold
+// meaningful executable bits. On Windows they do not, so all files
+// in the hooks and actions directories are made executable when
+// a charm is archived.
+var posixModes = runtime.GOOS != "windows"
+
 type zipPacker struct {
 	*zip.Writer
-	root  string
-	hooks map[string]bool
+	root    string
+	hooks   map[string]bool
+	exclude map[string]bool
 }
 
 func (zp *zipPacker) WalkFunc() filepath.WalkFunc {
@@ -251,11 +283,15 @@ func (zp *zipPacker) visit(path string, fi os.FileInfo, err error) error {
 	if mode&os.ModeSymlink != 0 {
 		method = zip.Store
 	}
-	if hidden || relpath == "revision" {
+	// The blob manifest of an expanded thin charm describes the
+	// archive it came from, not the directory, so it is never
+	// archived; ArchiveThinTo writes a new one.
+	if hidden || relpath == "revision" || relpath == blobsManifest || zp.exclude[filepath.ToSlash(relpath)] {
 		return nil
 	}
 	h := &zip.FileHeader{
-		Name:   relpath,
+		// Zip file names always use forward slashes.
+		Name:   filepath.ToSlash(relpath),
 		Method: method,
 	}
 
@@ -272,6 +308,12 @@ func (zp *zipPacker) visit(path string, fi os.FileInfo, err error) error {
 			perm = perm | 0100
 		}
 	}
+	if !posixModes && mode.IsRegular() {
+		switch filepath.Dir(relpath) {
+		case "hooks", "actions":
+			perm = 0755
+		}
+	}
 	h.SetMode(mode&^0777 | perm)
 
 	w, err := zp.CreateHeader(h)
@@ -284,6 +326,7 @@ func (zp *zipPacker) visit(path string, fi os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		target = filepath.ToSlash(target)
 		if err := checkSymlinkTarget(zp.root, relpath, target); err != nil {
 			return err
 		}
@@ -301,10 +344,10 @@ func (zp *zipPacker) visit(path string, fi os.FileInfo, err error) error {
 }
 
 func checkSymlinkTarget(basedir, symlink, target string) error {
-	if filepath.IsAbs(target) {
+	if filepath.IsAbs(target) || strings.HasPrefix(target, "/") {
 		return fmt.Errorf("symlink %q is absolute: %q", symlink, target)
 	}
-	p := filepath.Join(filepath.Dir(symlink), target)
+	p := filepath.ToSlash(filepath.Join(filepath.Dir(symlink), filepath.FromSlash(target)))
 	if p == ".." || strings.HasPrefix(p, "../") {
 		return fmt.Errorf("symlink %q links out of charm: %q", symlink, target)
 	}
@@ -288,6 +288,37 @@ func (s *CharmDirSuite) TestArchiveToWithNonExecutableHooks(c *gc.C) {
 	}
 }
 
+func (s *CharmDirSuite) TestArchiveToWithoutPosixModes(c *gc.C) {
+	s.PatchValue(charm.PosixModes, false)
+	charmDir := cloneDir(c, charmDirPath(c, "dummy"))
+	err := ioutil.WriteFile(filepath.Join(charmDir, "hooks", "common.sh"), nil, 0644)
+	c.Assert(err, gc.IsNil)
+	err = os.Mkdir(filepath.Join(charmDir, "actions"), 0755)
+	c.Assert(err, gc.IsNil)
+	err = ioutil.WriteFile(filepath.Join(charmDir, "actions", "snapshot"), nil, 0644)
+	c.Assert(err, gc.IsNil)
+	err = os.Symlink("../src/hello.c", filepath.Join(charmDir, "hooks", "hello.c"))
+	c.Assert(err, gc.IsNil)
+
+	dir, err := charm.ReadCharmDir(charmDir)
+	c.Assert(err, gc.IsNil)
+	var buf bytes.Buffer
+	err = dir.ArchiveTo(&buf)
+	c.Assert(err, gc.IsNil)
+	zipr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	c.Assert(err, gc.IsNil)
+
+	modes := make(map[string]os.FileMode)
+	for _, zfile := range zipr.File {
+		modes[zfile.Name] = zfile.Mode()
+	}
+	c.Assert(modes["hooks/common.sh"], gc.Equals, os.FileMode(0755))
+	c.Assert(modes["actions/snapshot"], gc.Equals, os.FileMode(0755))
+	c.Assert(modes["hooks/hello.c"], gc.Equals, os.ModeSymlink|0777)
+	c.Assert(modes["metadata.yaml"], gc.Equals, os.FileMode(0644))
+	c.Assert(modes["actions/"], gc.Equals, os.ModeDir|0755)
+}
+
 func (s *CharmDirSuite) TestArchiveToWithBadType(c *gc.C) {
 	charmDir := cloneDir(c, charmDirPath(c, "dummy"))
 	badFile := filepath.Join(charmDir, "hooks", "badfile")
@@ -0,0 +1,362 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/fs"
+	"gopkg.in/yaml.v2"
+
+	"gopkg.in/juju/charm.v6/resource"
+)
+
+// CharmDirEditor stages changes to a charm directory in memory.
+// The directory is not modified until Commit is called; the
+// changes may also be archived without modifying the directory
+// by calling ArchiveTo.
+type CharmDirEditor struct {
+	dir    *CharmDir
+	meta   *Meta
+	config *Config
+
+	// changes holds the staged file contents keyed by
+	// slash-separated path. A nil entry marks a removed file.
+	changes map[string]*stagedFile
+}
+
+type stagedFile struct {
+	data []byte
+	mode os.FileMode
+}
+
+// Edit returns an editor that stages changes to the charm directory.
+func (dir *CharmDir) Edit() *CharmDirEditor {
+	return &CharmDirEditor{
+		dir:     dir,
+		meta:    copyMeta(dir.meta),
+		config:  copyConfig(dir.config),
+		changes: make(map[string]*stagedFile),
+	}
+}
+
+// Meta returns the metadata of the charm, including any staged changes.
+// The returned value is owned by the editor; changes made to it do not
+// affect the charm directory and are only written by SetMeta.
+func (e *CharmDirEditor) Meta() *Meta {
+	return e.meta
+}
+
+// Config returns the configuration of the charm, including any
+// staged changes. As with Meta, changes made to the returned value
+// are only written by SetConfig.
+func (e *CharmDirEditor) Config() *Config {
+	return e.config
+}
+
+// SetMeta stages a replacement for the charm's metadata.yaml file.
+func (e *CharmDirEditor) SetMeta(meta *Meta) error {
+	if err := meta.Check(); err != nil {
+		return errors.Trace(err)
+	}
+	data, err := yaml.Marshal(meta)
+	if err != nil {
+		return errors.Annotate(err, "cannot marshal metadata")
+	}
+	e.meta = copyMeta(meta)
+	e.changes["metadata.yaml"] = &stagedFile{data: data, mode: 0644}
+	return nil
+}
+
+// SetConfig stages a replacement for the charm's config.yaml file.
+func (e *CharmDirEditor) SetConfig(config *Config) error {
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return errors.Annotate(err, "cannot marshal config")
+	}
+	e.config = copyConfig(config)
+	e.changes["config.yaml"] = &stagedFile{data: data, mode: 0644}
+	return nil
+}
+
+// AddFile stages the addition of a file at the given slash-separated
+// path relative to the charm root, replacing any existing file.
+// The metadata and config files must be changed with SetMeta and
+// SetConfig instead.
+func (e *CharmDirEditor) AddFile(p string, data []byte, mode os.FileMode) error {
+	if err := checkEditPath(p); err != nil {
+		return errors.Trace(err)
+	}
+	if !mode.IsRegular() {
+		return errors.Errorf("cannot add %q: not a regular file mode", p)
+	}
+	e.changes[p] = &stagedFile{data: data, mode: mode}
+	return nil
+}
+
+// RemoveFile stages the removal of the file at the given
+// slash-separated path relative to the charm root.
+func (e *CharmDirEditor) RemoveFile(p string) error {
+	if err := checkEditPath(p); err != nil {
+		return errors.Trace(err)
+	}
+	return stageRemoval(e.dir.Path, e.changes, p)
+}
+
+// stageRemoval records the removal of the file at p in changes,
+// returning an error if there is no such file either staged
+// in changes or in the directory at root.
+func stageRemoval(root string, changes map[string]*stagedFile, p string) error {
+	if f, ok := changes[p]; ok {
+		if f == nil {
+			return errors.NotFoundf("file %q", p)
+		}
+	} else {
+		info, err := os.Lstat(filepath.Join(root, filepath.FromSlash(p)))
+		if os.IsNotExist(err) {
+			return errors.NotFoundf("file %q", p)
+		}
+		if err != nil {
+			return errors.Trace(err)
+		}
+		if info.IsDir() {
+			return errors.Errorf("cannot remove %q: is a directory", p)
+		}
+	}
+	changes[p] = nil
+	return nil
+}
+
+// Commit writes the staged changes to the charm directory. The
+// changes are applied with CharmDir.UpdateFiles, so they are made to a
+// copy of the directory which then replaces the original; if writing
+// any of them fails or the result is not a valid charm, the directory
+// is left unchanged and the changes remain staged.
+func (e *CharmDirEditor) Commit() error {
+	err := e.dir.UpdateFiles(func(tx *Tx) error {
+		for p, f := range e.changes {
+			tx.changes[p] = f
+		}
+		return nil
+	})
+	if err != nil {
+		return errors.Trace(err)
+	}
+	e.changes = make(map[string]*stagedFile)
+	return nil
+}
+
+// ArchiveTo writes an archive of the charm, including the staged
+// changes, to w. The charm directory is not modified.
+func (e *CharmDirEditor) ArchiveTo(w io.Writer) error {
+	versionString, err := e.dir.MaybeGenerateVersionString()
+	if err != nil {
+		logger.Warningf("version string generation failed : %v", err)
+	}
+	rootPath, err := resolveSymlinkedRoot(e.dir.Path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tempDir, err := ioutil.TempDir("", "charm-edit")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer os.RemoveAll(tempDir)
+	copyPath := filepath.Join(tempDir, "charm")
+	if err := fs.Copy(rootPath, copyPath); err != nil {
+		return errors.Annotate(err, "cannot copy charm directory")
+	}
+	if err := writeStagedFiles(copyPath, e.changes); err != nil {
+		return errors.Trace(err)
+	}
+	return writeArchiveExcluding(w, copyPath, e.dir.revision, versionString, e.meta.Hooks(), attachedResourcePaths(copyPath, e.meta), nil)
+}
+
+// writeStagedFiles applies the given changes to the directory at root.
+func writeStagedFiles(root string, changes map[string]*stagedFile) (err error) {
+	paths := make([]string, 0, len(changes))
+	for p := range changes {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	// Write everything to temporary files first.
+	temps := make(map[string]string)
+	defer func() {
+		if err != nil {
+			for _, temp := range temps {
+				os.Remove(temp)
+			}
+		}
+	}()
+	for _, p := range paths {
+		f := changes[p]
+		if f == nil {
+			continue
+		}
+		target := filepath.Join(root, filepath.FromSlash(p))
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return errors.Trace(err)
+		}
+		temp, err := writeTempFile(filepath.Dir(target), f)
+		if err != nil {
+			return errors.Annotatef(err, "cannot write %q", p)
+		}
+		temps[p] = temp
+	}
+
+	// Then move them into place.
+	for _, p := range paths {
+		target := filepath.Join(root, filepath.FromSlash(p))
+		if changes[p] == nil {
+			if err := os.Remove(target); err != nil && !os.IsNotExist(err) {
+				return errors.Annotatef(err, "cannot remove %q", p)
+			}
+			continue
+		}
+		if err := os.Rename(temps[p], target); err != nil {
+			return errors.Annotatef(err, "cannot replace %q", p)
+		}
+		delete(temps, p)
+	}
+	return nil
+}
+
+// writeTempFile writes f to a new hidden file in dir
+// and returns its path.
+func writeTempFile(dir string, f *stagedFile) (string, error) {
+	file, err := ioutil.TempFile(dir, ".charm-edit-")
+	if err != nil {
+		return "", err
+	}
+	_, err = file.Write(f.data)
+	if err == nil {
+		err = file.Chmod(f.mode.Perm())
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// checkEditPath returns an error if p cannot be changed
+// with AddFile or RemoveFile.
+func checkEditPath(p string) error {
+	if err := checkCharmFilePath(p); err != nil {
+		return err
+	}
+	switch p {
+	case "metadata.yaml":
+		return errors.Errorf("metadata.yaml must be changed with SetMeta")
+	case "config.yaml":
+		return errors.Errorf("config.yaml must be changed with SetConfig")
+	}
+	return nil
+}
+
+// checkCharmFilePath returns an error if p is not a clean
+// slash-separated path to a file inside the charm.
+func checkCharmFilePath(p string) error {
+	switch {
+	case p == "" || p == "." || path.IsAbs(p) || path.Clean(p) != p:
+		return errors.Errorf("invalid charm file path %q", p)
+	case p == ".." || strings.HasPrefix(p, "../"):
+		return errors.Errorf("charm file path %q is outside the charm", p)
+	}
+	return nil
+}
+
+// copyMeta returns a deep copy of meta.
+func copyMeta(meta *Meta) *Meta {
+	if meta == nil {
+		return nil
+	}
+	m := *meta
+	m.Provides = copyRelations(meta.Provides)
+	m.Requires = copyRelations(meta.Requires)
+	m.Peers = copyRelations(meta.Peers)
+	if meta.ExtraBindings != nil {
+		m.ExtraBindings = make(map[string]ExtraBinding, len(meta.ExtraBindings))
+		for name, binding := range meta.ExtraBindings {
+			m.ExtraBindings[name] = binding
+		}
+	}
+	m.Categories = copyStrings(meta.Categories)
+	m.Tags = copyStrings(meta.Tags)
+	m.Series = copyStrings(meta.Series)
+	if meta.Bases != nil {
+		m.Bases = append([]Base(nil), meta.Bases...)
+	}
+	if meta.Storage != nil {
+		m.Storage = make(map[string]Storage, len(meta.Storage))
+		for name, store := range meta.Storage {
+			store.Properties = copyStrings(store.Properties)
+			m.Storage[name] = store
+		}
+	}
+	if meta.Devices != nil {
+		m.Devices = make(map[string]Device, len(meta.Devices))
+		for name, device := range meta.Devices {
+			m.Devices[name] = device
+		}
+	}
+	if meta.PayloadClasses != nil {
+		m.PayloadClasses = make(map[string]PayloadClass, len(meta.PayloadClasses))
+		for name, class := range meta.PayloadClasses {
+			m.PayloadClasses[name] = class
+		}
+	}
+	if meta.Resources != nil {
+		m.Resources = make(map[string]resource.Meta, len(meta.Resources))
+		for name, res := range meta.Resources {
+			m.Resources[name] = res
+		}
+	}
+	m.Terms = copyStrings(meta.Terms)
+	return &m
+}
+
+func copyRelations(relations map[string]Relation) map[string]Relation {
+	if relations == nil {
+		return nil
+	}
+	result := make(map[string]Relation, len(relations))
+	for name, relation := range relations {
+		result[name] = relation
+	}
+	return result
+}
+
+func copyStrings(s []string) []string {
+	if s == nil {
+		return nil
+	}
+	return append([]string(nil), s...)
+}
+
+// copyConfig returns a deep copy of config.
+func copyConfig(config *Config) *Config {
+	if config == nil {
+		return nil
+	}
+	c := &Config{}
+	if config.Options != nil {
+		c.Options = make(map[string]Option, len(config.Options))
+		for name, option := range config.Options {
+			c.Options[name] = option
+		}
+	}
+	return c
+}
@@ -0,0 +1,270 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type CharmDirEditSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&CharmDirEditSuite{})
+
+func (s *CharmDirEditSuite) editDummy(c *gc.C) (*charm.CharmDir, *charm.CharmDirEditor) {
+	dir, err := charm.ReadCharmDir(cloneDir(c, charmDirPath(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	e := dir.Edit()
+
+	meta := *dir.Meta()
+	meta.Summary = "edited summary"
+	err = e.SetMeta(&meta)
+	c.Assert(err, jc.ErrorIsNil)
+	err = e.SetConfig(&charm.Config{
+		Options: map[string]charm.Option{
+			"colour": {Type: "string", Default: "blue"},
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	err = e.AddFile("hooks/start", []byte("#!/bin/sh\n"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = e.AddFile("templates/new/file.txt", []byte("hello"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	err = e.RemoveFile("src/hello.c")
+	c.Assert(err, jc.ErrorIsNil)
+	return dir, e
+}
+
+func (s *CharmDirEditSuite) checkEdited(c *gc.C, path string) {
+	ch, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ch.Meta().Summary, gc.Equals, "edited summary")
+	c.Assert(ch.Config().Options, jc.DeepEquals, map[string]charm.Option{
+		"colour": {Type: "string", Default: "blue"},
+	})
+	info, err := os.Stat(filepath.Join(path, "hooks", "start"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode()&0777, gc.Equals, os.FileMode(0755))
+	data, err := ioutil.ReadFile(filepath.Join(path, "templates", "new", "file.txt"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, "hello")
+	_, err = os.Stat(filepath.Join(path, "src", "hello.c"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+}
+
+func (s *CharmDirEditSuite) TestCommit(c *gc.C) {
+	dir, e := s.editDummy(c)
+
+	// Nothing changes until the edits are committed.
+	_, err := os.Stat(filepath.Join(dir.Path, "src", "hello.c"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dir.Meta().Summary, gc.Equals, "That's a dummy charm.")
+
+	err = e.Commit()
+	c.Assert(err, jc.ErrorIsNil)
+	s.checkEdited(c, dir.Path)
+	c.Assert(dir.Meta().Summary, gc.Equals, "edited summary")
+	c.Assert(dir.Config().Options, gc.HasLen, 1)
+
+	// No temporary files are left behind.
+	entries, err := ioutil.ReadDir(filepath.Join(dir.Path, "hooks"))
+	c.Assert(err, jc.ErrorIsNil)
+	for _, entry := range entries {
+		c.Check(entry.Name(), gc.Not(gc.Matches), `\.charm-edit-.*`)
+	}
+}
+
+func (s *CharmDirEditSuite) TestCommitError(c *gc.C) {
+	dir, e := s.editDummy(c)
+	err := e.AddFile("actions.yaml", []byte("bad: [yaml"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = e.Commit()
+	c.Assert(err, gc.ErrorMatches, `updated charm is invalid: .*`)
+
+	// None of the changes have been made.
+	_, err = os.Stat(filepath.Join(dir.Path, "src", "hello.c"))
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = os.Stat(filepath.Join(dir.Path, "templates"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+	ch, err := charm.ReadCharmDir(dir.Path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(ch.Meta().Summary, gc.Equals, "That's a dummy charm.")
+	c.Assert(dir.Meta().Summary, gc.Equals, "That's a dummy charm.")
+	entries, err := ioutil.ReadDir(filepath.Dir(dir.Path))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 1)
+
+	// The changes are still staged, so they can be committed
+	// once the problem has been fixed.
+	err = e.RemoveFile("actions.yaml")
+	c.Assert(err, jc.ErrorIsNil)
+	err = e.Commit()
+	c.Assert(err, jc.ErrorIsNil)
+	s.checkEdited(c, dir.Path)
+}
+
+func (s *CharmDirEditSuite) TestArchiveTo(c *gc.C) {
+	dir, e := s.editDummy(c)
+	var buf bytes.Buffer
+	err := e.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// The directory itself is untouched.
+	_, err = os.Stat(filepath.Join(dir.Path, "src", "hello.c"))
+	c.Assert(err, jc.ErrorIsNil)
+	_, err = os.Stat(filepath.Join(dir.Path, "templates"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+
+	archive, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	target := filepath.Join(c.MkDir(), "charm")
+	err = archive.ExpandTo(target)
+	c.Assert(err, jc.ErrorIsNil)
+	s.checkEdited(c, target)
+}
+
+func (s *CharmDirEditSuite) TestSetMetaRoundTrip(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	err := ioutil.WriteFile(filepath.Join(path, "metadata.yaml"), []byte(`
+name: dummy
+summary: s
+description: d
+storage:
+    data:
+        type: filesystem
+        location: /srv/data
+        multiple:
+            range: 1-3
+        minimum-size: 1G
+    logs:
+        type: block
+        read-only: true
+        multiple:
+            range: 2+
+devices:
+    gpu:
+        type: nvidia.com/gpu
+        countmin: 1
+        countmax: 2
+payloads:
+    monitor:
+        type: docker
+`), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+
+	e := dir.Edit()
+	err = e.SetMeta(e.Meta())
+	c.Assert(err, jc.ErrorIsNil)
+	err = e.Commit()
+	c.Assert(err, jc.ErrorIsNil)
+
+	got, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(got.Meta(), jc.DeepEquals, dir.Meta())
+	c.Assert(got.Meta().Storage["data"].CountMax, gc.Equals, 3)
+	c.Assert(got.Meta().Storage["data"].MinimumSize, gc.Equals, uint64(1024))
+	c.Assert(got.Meta().PayloadClasses, gc.HasLen, 1)
+}
+
+func (s *CharmDirEditSuite) TestSetMetaInvalid(c *gc.C) {
+	dir := readCharmDir(c, "dummy")
+	e := dir.Edit()
+	err := e.SetMeta(&charm.Meta{Name: "a", Series: []string{"Bad"}})
+	c.Assert(err, gc.ErrorMatches, `charm "a" declares invalid series: "Bad"`)
+	c.Assert(e.Meta(), jc.DeepEquals, dir.Meta())
+}
+
+func (s *CharmDirEditSuite) TestEditCopiesMetaAndConfig(c *gc.C) {
+	dir := readCharmDir(c, "wordpress")
+	e := dir.Edit()
+	e.Meta().Summary = "changed"
+	e.Meta().Provides["foo"] = charm.Relation{Name: "foo"}
+	e.Config().Options["new-option"] = charm.Option{Type: "string"}
+
+	c.Assert(dir.Meta().Summary, gc.Equals, "Blog engine")
+	_, ok := dir.Meta().Provides["foo"]
+	c.Assert(ok, jc.IsFalse)
+	_, ok = dir.Config().Options["new-option"]
+	c.Assert(ok, jc.IsFalse)
+}
+
+func (s *CharmDirEditSuite) TestSetMetaAndCommitCopy(c *gc.C) {
+	dir, err := charm.ReadCharmDir(cloneDir(c, charmDirPath(c, "dummy")))
+	c.Assert(err, jc.ErrorIsNil)
+	e := dir.Edit()
+	meta := *dir.Meta()
+	meta.Summary = "edited summary"
+	err = e.SetMeta(&meta)
+	c.Assert(err, jc.ErrorIsNil)
+	meta.Summary = "changed after SetMeta"
+	c.Assert(e.Meta().Summary, gc.Equals, "edited summary")
+
+	err = e.Commit()
+	c.Assert(err, jc.ErrorIsNil)
+	e.Meta().Summary = "changed after Commit"
+	c.Assert(dir.Meta().Summary, gc.Equals, "edited summary")
+}
+
+func (s *CharmDirEditSuite) TestInvalidPaths(c *gc.C) {
+	e := readCharmDir(c, "dummy").Edit()
+	for i, test := range []struct {
+		path        string
+		expectError string
+	}{{
+		path:        "",
+		expectError: `invalid charm file path ""`,
+	}, {
+		path:        "/etc/passwd",
+		expectError: `invalid charm file path "/etc/passwd"`,
+	}, {
+		path:        "hooks/../install",
+		expectError: `invalid charm file path "hooks/../install"`,
+	}, {
+		path:        "../elsewhere",
+		expectError: `charm file path "../elsewhere" is outside the charm`,
+	}, {
+		path:        "metadata.yaml",
+		expectError: `metadata.yaml must be changed with SetMeta`,
+	}, {
+		path:        "config.yaml",
+		expectError: `config.yaml must be changed with SetConfig`,
+	}} {
+		c.Logf("test %d: %q", i, test.path)
+		err := e.AddFile(test.path, nil, 0644)
+		c.Check(err, gc.ErrorMatches, test.expectError)
+		err = e.RemoveFile(test.path)
+		c.Check(err, gc.ErrorMatches, test.expectError)
+	}
+}
+
+func (s *CharmDirEditSuite) TestRemoveFile(c *gc.C) {
+	e := readCharmDir(c, "dummy").Edit()
+	err := e.RemoveFile("no-such-file")
+	c.Assert(err, gc.ErrorMatches, `file "no-such-file" not found`)
+	c.Assert(errors.IsNotFound(err), jc.IsTrue)
+
+	err = e.RemoveFile("src")
+	c.Assert(err, gc.ErrorMatches, `cannot remove "src": is a directory`)
+
+	err = e.AddFile("staged", []byte("x"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	err = e.RemoveFile("staged")
+	c.Assert(err, jc.ErrorIsNil)
+	err = e.RemoveFile("staged")
+	c.Assert(err, gc.ErrorMatches, `file "staged" not found`)
+}
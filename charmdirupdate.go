@@ -0,0 +1,136 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/juju/errors"
+	"github.com/juju/utils/fs"
+)
+
+// Tx holds file changes staged by the function passed to
+// CharmDir.UpdateFiles.
+type Tx struct {
+	root string
+
+	// changes holds the staged file contents keyed by
+	// slash-separated path. A nil entry marks a removed file.
+	changes map[string]*stagedFile
+}
+
+// WriteFile stages a write of data to the file at the given
+// slash-separated path relative to the charm root, replacing
+// any existing file. Unlike CharmDirEditor.AddFile, the path
+// may name metadata.yaml or config.yaml.
+func (tx *Tx) WriteFile(p string, data []byte, mode os.FileMode) error {
+	if err := checkCharmFilePath(p); err != nil {
+		return errors.Trace(err)
+	}
+	if !mode.IsRegular() {
+		return errors.Errorf("cannot write %q: not a regular file mode", p)
+	}
+	tx.changes[p] = &stagedFile{data: data, mode: mode}
+	return nil
+}
+
+// RemoveFile stages the removal of the file at the given
+// slash-separated path relative to the charm root.
+func (tx *Tx) RemoveFile(p string) error {
+	if err := checkCharmFilePath(p); err != nil {
+		return errors.Trace(err)
+	}
+	return stageRemoval(tx.root, tx.changes, p)
+}
+
+// SetRevision stages a write of the charm's revision file.
+func (tx *Tx) SetRevision(revision int) {
+	tx.changes["revision"] = &stagedFile{
+		data: []byte(strconv.Itoa(revision)),
+		mode: 0644,
+	}
+}
+
+// UpdateFiles calls f to stage changes to the files in the charm
+// directory and then applies them all at once. If f returns an error,
+// nothing is changed and the error is returned.
+//
+// The changes are made to a copy of the directory, created in a
+// hidden ".charm-update-*" directory alongside it, which replaces
+// the original only if every change succeeds and the result is still
+// a valid charm. The replacement takes two renames: the original is
+// first moved into the hidden directory and the copy is then moved
+// into its place. Between the two the charm directory does not exist,
+// and if the process crashes in that window the original and updated
+// charms are left only in the hidden directory. Any other failure
+// leaves the original directory unchanged.
+//
+// On success, the metadata, configuration, metrics and actions
+// of dir are reloaded from the updated directory.
+func (dir *CharmDir) UpdateFiles(f func(tx *Tx) error) error {
+	rootPath, err := resolveSymlinkedRoot(dir.Path)
+	if err != nil {
+		return errors.Trace(err)
+	}
+	tx := &Tx{
+		root:    rootPath,
+		changes: make(map[string]*stagedFile),
+	}
+	if err := f(tx); err != nil {
+		return errors.Trace(err)
+	}
+	if len(tx.changes) == 0 {
+		return nil
+	}
+
+	// Create the working directory next to the charm so that
+	// the final renames stay within one file system.
+	tempDir, err := ioutil.TempDir(filepath.Dir(rootPath), ".charm-update-")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	keepTempDir := false
+	defer func() {
+		if !keepTempDir {
+			os.RemoveAll(tempDir)
+		}
+	}()
+	newPath := filepath.Join(tempDir, "new")
+	if err := fs.Copy(rootPath, newPath); err != nil {
+		return errors.Annotate(err, "cannot copy charm directory")
+	}
+	if err := writeStagedFiles(newPath, tx.changes); err != nil {
+		return errors.Trace(err)
+	}
+	updated, err := ReadCharmDir(newPath)
+	if err != nil {
+		return errors.Annotate(err, "updated charm is invalid")
+	}
+
+	oldPath := filepath.Join(tempDir, "old")
+	if err := os.Rename(rootPath, oldPath); err != nil {
+		return errors.Annotate(err, "cannot move charm directory aside")
+	}
+	if err := os.Rename(newPath, rootPath); err != nil {
+		if restoreErr := os.Rename(oldPath, rootPath); restoreErr != nil {
+			// Leave the original files where they are so
+			// that they can be recovered by hand.
+			keepTempDir = true
+			logger.Errorf("cannot restore charm directory %q from %q: %v", rootPath, oldPath, restoreErr)
+		}
+		return errors.Annotate(err, "cannot replace charm directory")
+	}
+
+	dir.meta = updated.meta
+	dir.config = updated.config
+	dir.metrics = updated.metrics
+	dir.actions = updated.actions
+	if _, ok := tx.changes["revision"]; ok {
+		dir.revision = updated.revision
+	}
+	return nil
+}
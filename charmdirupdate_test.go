@@ -0,0 +1,116 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type CharmDirUpdateSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&CharmDirUpdateSuite{})
+
+func (s *CharmDirUpdateSuite) TestUpdateFiles(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = dir.UpdateFiles(func(tx *charm.Tx) error {
+		if err := tx.WriteFile("metadata.yaml", []byte("name: dummy\nsummary: updated\ndescription: d\n"), 0644); err != nil {
+			return err
+		}
+		if err := tx.WriteFile("hooks/start", []byte("#!/bin/sh\n"), 0755); err != nil {
+			return err
+		}
+		tx.SetRevision(42)
+		return tx.RemoveFile("src/hello.c")
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dir.Path, gc.Equals, path)
+	c.Assert(dir.Meta().Summary, gc.Equals, "updated")
+	c.Assert(dir.Revision(), gc.Equals, 42)
+
+	dir, err = charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dir.Meta().Summary, gc.Equals, "updated")
+	c.Assert(dir.Revision(), gc.Equals, 42)
+	info, err := os.Stat(filepath.Join(path, "hooks", "start"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(info.Mode()&0777, gc.Equals, os.FileMode(0755))
+	_, err = os.Stat(filepath.Join(path, "src", "hello.c"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+
+	// No working directories are left behind.
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 1)
+}
+
+func (s *CharmDirUpdateSuite) TestUpdateFilesError(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = dir.UpdateFiles(func(tx *charm.Tx) error {
+		tx.SetRevision(42)
+		return errors.New("generator failed")
+	})
+	c.Assert(err, gc.ErrorMatches, "generator failed")
+
+	err = dir.UpdateFiles(func(tx *charm.Tx) error {
+		tx.SetRevision(42)
+		return tx.WriteFile("metadata.yaml", []byte("name: dummy\nsummary: s\n"), 0644)
+	})
+	c.Assert(err, gc.ErrorMatches, `updated charm is invalid: metadata: description: expected string, got nothing`)
+
+	s.assertUnchanged(c, path)
+	c.Assert(dir.Revision(), gc.Equals, 1)
+	c.Assert(dir.Meta().Summary, gc.Equals, "That's a dummy charm.")
+}
+
+func (s *CharmDirUpdateSuite) TestUpdateFilesInvalidPaths(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+
+	err = dir.UpdateFiles(func(tx *charm.Tx) error {
+		return tx.WriteFile("../outside", nil, 0644)
+	})
+	c.Assert(err, gc.ErrorMatches, `charm file path "../outside" is outside the charm`)
+
+	err = dir.UpdateFiles(func(tx *charm.Tx) error {
+		return tx.WriteFile("/etc/passwd", nil, 0644)
+	})
+	c.Assert(err, gc.ErrorMatches, `invalid charm file path "/etc/passwd"`)
+
+	err = dir.UpdateFiles(func(tx *charm.Tx) error {
+		return tx.RemoveFile("no-such-file")
+	})
+	c.Assert(err, jc.Satisfies, errors.IsNotFound)
+
+	s.assertUnchanged(c, path)
+}
+
+func (s *CharmDirUpdateSuite) assertUnchanged(c *gc.C, path string) {
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(dir.Revision(), gc.Equals, 1)
+	c.Assert(dir.Meta().Summary, gc.Equals, "That's a dummy charm.")
+	_, err = os.Stat(filepath.Join(path, "src", "hello.c"))
+	c.Assert(err, jc.ErrorIsNil)
+	entries, err := ioutil.ReadDir(filepath.Dir(path))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 1)
+}
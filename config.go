@@ -86,12 +86,29 @@ func NewConfig() *Config {
 	return &Config{map[string]Option{}}
 }
 
-// ReadConfig reads a Config in YAML format.
+// ReadConfig reads a Config in YAML format. Keys that are defined
+// more than once are logged as warnings; the last definition wins.
 func ReadConfig(r io.Reader) (*Config, error) {
+	return readConfig(r, false)
+}
+
+// ReadConfigStrict is like ReadConfig except that it returns a
+// *DuplicateKeysError if any key is defined more than once.
+func ReadConfigStrict(r io.Reader) (*Config, error) {
+	return readConfig(r, true)
+}
+
+func readConfig(r io.Reader, strict bool) (*Config, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	if data, err = normalizeYAML("config", data); err != nil {
+		return nil, err
+	}
+	if err := checkDuplicateKeys("config", data, strict); err != nil {
+		return nil, err
+	}
 	var config *Config
 	if err := yaml.Unmarshal(data, &config); err != nil {
 		return nil, err
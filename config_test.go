@@ -411,6 +411,27 @@ func (s *ConfigSuite) TestConfigWithNoOptions(c *gc.C) {
 	c.Assert(err, gc.IsNil)
 }
 
+func (s *ConfigSuite) TestConfigDuplicateKeys(c *gc.C) {
+	data := "options:\n  title:\n    type: string\n    default: one\n    default: two\n"
+	config, err := charm.ReadConfig(strings.NewReader(data))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config.Options["title"].Default, gc.Equals, "two")
+
+	_, err = charm.ReadConfigStrict(strings.NewReader(data))
+	c.Assert(err, gc.ErrorMatches, `config: duplicate keys: line 5: key "default" already set in map`)
+	c.Assert(charm.IsDuplicateKeysError(err), jc.IsTrue)
+}
+
+func (s *ConfigSuite) TestConfigEncoding(c *gc.C) {
+	config, err := charm.ReadConfig(strings.NewReader("\ufeffoptions:\n  title: {type: string}\n"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(config.Options["title"].Type, gc.Equals, "string")
+
+	_, err = charm.ReadConfig(strings.NewReader("options:\n  title: {type: string, default: \xc3}\n"))
+	c.Assert(err, gc.ErrorMatches, `config.yaml: invalid UTF-8 at byte offset 42 .*`)
+	c.Assert(charm.IsEncodingError(err), jc.IsTrue)
+}
+
 func (s *ConfigSuite) TestDefaultType(c *gc.C) {
 	assertDefault := func(type_ string, value string, expected interface{}) {
 		config := fmt.Sprintf(`options: {x: {type: %s, default: %s}}`, type_, value)
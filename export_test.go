@@ -14,6 +14,9 @@ var (
 	ExtraBindingsSchema       = extraBindingsSchema
 	ValidateMetaExtraBindings = validateMetaExtraBindings
 	ParseResourceMeta         = parseResourceMeta
+
+	PosixModes        = &posixModes
+	SymlinksSupported = &symlinksSupported
 )
 
 func MissingSeriesError() error {
@@ -4,6 +4,11 @@
 // hooks provides types and constants that define the hooks known to Juju.
 package hooks
 
+import (
+	"fmt"
+	"strings"
+)
+
 // Kind enumerates the different kinds of hooks that exist.
 type Kind string
 
@@ -112,3 +117,24 @@ func (kind Kind) IsStorage() bool {
 	}
 	return false
 }
+
+// KindOf returns the kind of the hook with the given file name.
+// For relation and storage hooks, it also returns the name of the
+// relation or storage that the hook is for; for example, "db" for
+// "db-relation-joined". Note that action names are not hook names.
+func KindOf(name string) (Kind, string, error) {
+	for _, kind := range unitHooks {
+		if name == string(kind) {
+			return kind, "", nil
+		}
+	}
+	for _, kinds := range [][]Kind{relationHooks, storageHooks} {
+		for _, kind := range kinds {
+			prefix := strings.TrimSuffix(name, "-"+string(kind))
+			if prefix != name && prefix != "" {
+				return kind, prefix, nil
+			}
+		}
+	}
+	return "", "", fmt.Errorf("unknown hook %q", name)
+}
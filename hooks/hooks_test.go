@@ -0,0 +1,78 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package hooks_test
+
+import (
+	"testing"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6/hooks"
+)
+
+func Test(t *testing.T) {
+	gc.TestingT(t)
+}
+
+type HooksSuite struct{}
+
+var _ = gc.Suite(&HooksSuite{})
+
+var kindOfTests = []struct {
+	name         string
+	expectKind   hooks.Kind
+	expectPrefix string
+	expectError  string
+}{{
+	name:       "install",
+	expectKind: hooks.Install,
+}, {
+	name:       "leader-settings-changed",
+	expectKind: hooks.LeaderSettingsChanged,
+}, {
+	name:         "db-relation-joined",
+	expectKind:   hooks.RelationJoined,
+	expectPrefix: "db",
+}, {
+	name:         "logging-dir-relation-broken",
+	expectKind:   hooks.RelationBroken,
+	expectPrefix: "logging-dir",
+}, {
+	name:         "shared-fs-storage-attached",
+	expectKind:   hooks.StorageAttached,
+	expectPrefix: "shared-fs",
+}, {
+	name:        "relation-joined",
+	expectError: `unknown hook "relation-joined"`,
+}, {
+	name:        "action",
+	expectError: `unknown hook "action"`,
+}, {
+	name:        "db-relation-exploded",
+	expectError: `unknown hook "db-relation-exploded"`,
+}}
+
+func (s *HooksSuite) TestKindOf(c *gc.C) {
+	for i, test := range kindOfTests {
+		c.Logf("test %d: %s", i, test.name)
+		kind, prefix, err := hooks.KindOf(test.name)
+		if test.expectError != "" {
+			c.Check(err, gc.ErrorMatches, test.expectError)
+			continue
+		}
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(kind, gc.Equals, test.expectKind)
+		c.Check(prefix, gc.Equals, test.expectPrefix)
+	}
+}
+
+func (s *HooksSuite) TestKindOfAllUnitHooks(c *gc.C) {
+	for _, kind := range hooks.UnitHooks() {
+		got, prefix, err := hooks.KindOf(string(kind))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(got, gc.Equals, kind)
+		c.Check(prefix, gc.Equals, "")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"io"
 	"io/ioutil"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -34,6 +35,25 @@ const (
 	ScopeContainer RelationScope = "container"
 )
 
+// Maturity describes how stable a charm is considered to be.
+type Maturity string
+
+const (
+	MaturityExperimental Maturity = "experimental"
+	MaturityBeta         Maturity = "beta"
+	MaturityStable       Maturity = "stable"
+)
+
+// Validate returns an error if the maturity is not one
+// of the known values. The empty maturity is valid.
+func (m Maturity) Validate() error {
+	switch m {
+	case "", MaturityExperimental, MaturityBeta, MaturityStable:
+		return nil
+	}
+	return errors.NotValidf("maturity %q", string(m))
+}
+
 // RelationRole defines the role of a relation.
 type RelationRole string
 
@@ -147,15 +167,66 @@ type Device struct {
 	CountMax int64 `bson:"countmax"`
 }
 
+// InterfaceVersions holds the range of interface versions
+// that a relation supports. Both bounds are inclusive.
+// The zero value means that no versions were declared,
+// and the relation is taken to support any version.
+type InterfaceVersions struct {
+	Min int `bson:"min,omitempty"`
+	Max int `bson:"max,omitempty"`
+}
+
+// IsZero reports whether no interface versions were declared.
+func (v InterfaceVersions) IsZero() bool {
+	return v == InterfaceVersions{}
+}
+
+// Compatible reports whether the two version ranges have
+// at least one version in common. A relation that declares
+// no versions is compatible with any other.
+func (v InterfaceVersions) Compatible(other InterfaceVersions) bool {
+	if v.IsZero() || other.IsZero() {
+		return true
+	}
+	return v.Min <= other.Max && other.Min <= v.Max
+}
+
+// Validate returns an error if the version range is malformed.
+func (v InterfaceVersions) Validate() error {
+	if v.IsZero() {
+		return nil
+	}
+	if v.Min < 1 {
+		return fmt.Errorf("invalid minimum interface version %d", v.Min)
+	}
+	if v.Max < v.Min {
+		return fmt.Errorf("maximum interface version %d is less than minimum %d", v.Max, v.Min)
+	}
+	return nil
+}
+
 // Relation represents a single relation defined in the charm
 // metadata.yaml file.
+//
+// Versions holds the interface versions supported by the relation,
+// declared in metadata.yaml as either a single version or a list of
+// versions, such as [1, 2, 3]. The listed versions must form a
+// consecutive range; a list with gaps, such as [1, 3], is rejected.
 type Relation struct {
-	Name      string        `bson:"name"`
-	Role      RelationRole  `bson:"role"`
-	Interface string        `bson:"interface"`
-	Optional  bool          `bson:"optional"`
-	Limit     int           `bson:"limit"`
-	Scope     RelationScope `bson:"scope"`
+	Name      string            `bson:"name"`
+	Role      RelationRole      `bson:"role"`
+	Interface string            `bson:"interface"`
+	Versions  InterfaceVersions `bson:"versions,omitempty"`
+	Optional  bool              `bson:"optional"`
+	Limit     int               `bson:"limit"`
+	Scope     RelationScope     `bson:"scope"`
+}
+
+// CanRelateTo reports whether r and other use the same interface
+// with at least one interface version in common. It does not
+// take roles or scopes into account.
+func (r Relation) CanRelateTo(other Relation) bool {
+	return r.Interface == other.Interface && r.Versions.Compatible(other.Versions)
 }
 
 // ImplementedBy returns whether the relation is implemented by the supplied charm.
@@ -207,6 +278,10 @@ func (r Relation) IsImplicit() bool {
 // was unused in practice but still serialized. This
 // only applies to JSON because Meta has a custom
 // YAML marshaller.
+//
+// Charms must not hold a file named blobs.yaml at their root;
+// that name is reserved for the manifest of a thin charm
+// archive, as written by CharmDir.ArchiveThinTo.
 type Meta struct {
 	Name           string                   `bson:"name" json:"Name"`
 	Summary        string                   `bson:"summary" json:"Summary"`
@@ -219,12 +294,15 @@ type Meta struct {
 	Categories     []string                 `bson:"categories,omitempty" json:"Categories,omitempty"`
 	Tags           []string                 `bson:"tags,omitempty" json:"Tags,omitempty"`
 	Series         []string                 `bson:"series,omitempty" json:"SupportedSeries,omitempty"`
+	Bases          []Base                   `bson:"bases,omitempty" json:"Bases,omitempty"`
 	Storage        map[string]Storage       `bson:"storage,omitempty" json:"Storage,omitempty"`
 	Devices        map[string]Device        `bson:"devices,omitempty" json:"Devices,omitempty"`
 	PayloadClasses map[string]PayloadClass  `bson:"payloadclasses,omitempty" json:"PayloadClasses,omitempty"`
 	Resources      map[string]resource.Meta `bson:"resources,omitempty" json:"Resources,omitempty"`
 	Terms          []string                 `bson:"terms,omitempty" json:"Terms,omitempty"`
 	MinJujuVersion version.Number           `bson:"min-juju-version,omitempty" json:"min-juju-version,omitempty"`
+	Maturity       Maturity                 `bson:"maturity,omitempty" json:"Maturity,omitempty"`
+	ForkedFrom     string                   `bson:"forked-from,omitempty" json:"ForkedFrom,omitempty"`
 }
 
 func generateRelationHooks(relName string, allHooks map[string]bool) {
@@ -255,6 +333,34 @@ func (m Meta) Hooks() map[string]bool {
 	return allHooks
 }
 
+// IsRelationHook reports whether name is the name of a relation
+// hook for one of the charm's relations.
+func (m Meta) IsRelationHook(name string) bool {
+	kind, relName, err := hooks.KindOf(name)
+	if err != nil || !kind.IsRelation() {
+		return false
+	}
+	_, ok := m.CombinedRelations()[relName]
+	return ok
+}
+
+// ValidHookNames returns the sorted names of all the hooks that the
+// charm may implement. Unlike Hooks, it includes the hooks for the
+// charm's storage.
+func (m Meta) ValidHookNames() []string {
+	var names []string
+	for name := range m.Hooks() {
+		names = append(names, name)
+	}
+	for storageName := range m.Storage {
+		for _, kind := range hooks.StorageHooks() {
+			names = append(names, fmt.Sprintf("%s-%s", storageName, kind))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
 // Used for parsing Categories and Tags.
 func parseStringList(list interface{}) []string {
 	if list == nil {
@@ -268,6 +374,46 @@ func parseStringList(list interface{}) []string {
 	return result
 }
 
+// parseMaturity returns the charm's maturity, which
+// may also be specified as "stability".
+func parseMaturity(m map[string]interface{}) (Maturity, error) {
+	maturity, _ := m["maturity"].(string)
+	stability, _ := m["stability"].(string)
+	if maturity != "" && stability != "" && maturity != stability {
+		return "", errors.Errorf("maturity %q does not match stability %q", maturity, stability)
+	}
+	if maturity == "" {
+		maturity = stability
+	}
+	return Maturity(maturity), nil
+}
+
+func parseBases(list interface{}) ([]Base, error) {
+	if list == nil {
+		return nil, nil
+	}
+	var bases []Base
+	for _, s := range parseStringList(list) {
+		base, err := ParseBase(s)
+		if err != nil {
+			return nil, errors.Annotate(err, "invalid bases")
+		}
+		bases = append(bases, base)
+	}
+	return bases, nil
+}
+
+func marshaledBases(bases []Base) []string {
+	if len(bases) == 0 {
+		return nil
+	}
+	ss := make([]string, len(bases))
+	for i, base := range bases {
+		ss[i] = base.String()
+	}
+	return ss
+}
+
 var validTermName = regexp.MustCompile(`^[a-z](-?[a-z0-9]+)+$`)
 
 // TermsId represents a single term id. The term can either be owned
@@ -397,12 +543,29 @@ func MustParseTerm(s string) *TermsId {
 }
 
 // ReadMeta reads the content of a metadata.yaml file and returns
-// its representation.
+// its representation. Keys that are defined more than once are
+// logged as warnings; the last definition wins.
 func ReadMeta(r io.Reader) (*Meta, error) {
+	return readMeta(r, false)
+}
+
+// ReadMetaStrict is like ReadMeta except that it returns a
+// *DuplicateKeysError if any key is defined more than once.
+func ReadMetaStrict(r io.Reader) (*Meta, error) {
+	return readMeta(r, true)
+}
+
+func readMeta(r io.Reader, strict bool) (*Meta, error) {
 	data, err := ioutil.ReadAll(r)
 	if err != nil {
 		return nil, err
 	}
+	if data, err = normalizeYAML("metadata", data); err != nil {
+		return nil, err
+	}
+	if err := checkDuplicateKeys("metadata", data, strict); err != nil {
+		return nil, err
+	}
 	var meta Meta
 	err = yaml.Unmarshal(data, &meta)
 	if err != nil {
@@ -457,6 +620,9 @@ func parseMeta(m map[string]interface{}) (*Meta, error) {
 		meta.Subordinate = subordinate.(bool)
 	}
 	meta.Series = parseStringList(m["series"])
+	if meta.Bases, err = parseBases(m["bases"]); err != nil {
+		return nil, err
+	}
 	meta.Storage = parseStorage(m["storage"])
 	meta.Devices = parseDevices(m["devices"])
 	meta.PayloadClasses = parsePayloadClasses(m["payloads"])
@@ -469,6 +635,12 @@ func parseMeta(m map[string]interface{}) (*Meta, error) {
 		meta.MinJujuVersion = minver
 	}
 	meta.Terms = parseStringList(m["terms"])
+	if meta.Maturity, err = parseMaturity(m); err != nil {
+		return nil, err
+	}
+	if forkedFrom := m["forked-from"]; forkedFrom != nil {
+		meta.ForkedFrom = forkedFrom.(string)
+	}
 
 	resources, err := parseMetaResources(m["resources"])
 	if err != nil {
@@ -498,10 +670,14 @@ func (m Meta) MarshalYAML() (interface{}, error) {
 		Tags           []string                         `yaml:"tags,omitempty"`
 		Subordinate    bool                             `yaml:"subordinate,omitempty"`
 		Series         []string                         `yaml:"series,omitempty"`
-		Storage        map[string]Storage               `yaml:"storage,omitempty"`
-		Devices        map[string]Device                `yaml:"devices,omitempty"`
+		Bases          []string                         `yaml:"bases,omitempty"`
+		Storage        map[string]marshaledStorage      `yaml:"storage,omitempty"`
+		Devices        map[string]marshaledDevice       `yaml:"devices,omitempty"`
+		PayloadClasses map[string]marshaledPayloadClass `yaml:"payloads,omitempty"`
 		Terms          []string                         `yaml:"terms,omitempty"`
 		MinJujuVersion string                           `yaml:"min-juju-version,omitempty"`
+		Maturity       Maturity                         `yaml:"maturity,omitempty"`
+		ForkedFrom     string                           `yaml:"forked-from,omitempty"`
 		Resources      map[string]marshaledResourceMeta `yaml:"resources,omitempty"`
 	}{
 		Name:           m.Name,
@@ -515,10 +691,14 @@ func (m Meta) MarshalYAML() (interface{}, error) {
 		Tags:           m.Tags,
 		Subordinate:    m.Subordinate,
 		Series:         m.Series,
-		Storage:        m.Storage,
-		Devices:        m.Devices,
+		Bases:          marshaledBases(m.Bases),
+		Storage:        marshaledStorages(m.Storage),
+		Devices:        marshaledDevices(m.Devices),
+		PayloadClasses: marshaledPayloadClasses(m.PayloadClasses),
 		Terms:          m.Terms,
 		MinJujuVersion: minver,
+		Maturity:       m.Maturity,
+		ForkedFrom:     m.ForkedFrom,
 		Resources:      marshaledResources(m.Resources),
 	}, nil
 }
@@ -544,6 +724,79 @@ func marshaledResources(rs map[string]resource.Meta) map[string]marshaledResourc
 	return rs1
 }
 
+type marshaledStorage struct {
+	Type        StorageType            `yaml:"type"`
+	Description string                 `yaml:"description,omitempty"`
+	Shared      bool                   `yaml:"shared,omitempty"`
+	ReadOnly    bool                   `yaml:"read-only,omitempty"`
+	Multiple    map[string]interface{} `yaml:"multiple,omitempty"`
+	MinimumSize string                 `yaml:"minimum-size,omitempty"`
+	Location    string                 `yaml:"location,omitempty"`
+	Properties  []string               `yaml:"properties,omitempty"`
+}
+
+func marshaledStorages(stores map[string]Storage) map[string]marshaledStorage {
+	marshaled := make(map[string]marshaledStorage, len(stores))
+	for name, store := range stores {
+		ms := marshaledStorage{
+			Type:        store.Type,
+			Description: store.Description,
+			Shared:      store.Shared,
+			ReadOnly:    store.ReadOnly,
+			Location:    store.Location,
+			Properties:  store.Properties,
+		}
+		// See storageCountC for the accepted forms of the range.
+		switch {
+		case store.CountMin == 1 && store.CountMax == 1:
+		case store.CountMax == -1:
+			ms.Multiple = map[string]interface{}{"range": fmt.Sprintf("%d+", store.CountMin)}
+		case store.CountMin == store.CountMax:
+			ms.Multiple = map[string]interface{}{"range": store.CountMin}
+		default:
+			ms.Multiple = map[string]interface{}{"range": fmt.Sprintf("%d-%d", store.CountMin, store.CountMax)}
+		}
+		if store.MinimumSize != 0 {
+			// MinimumSize is held in MiB.
+			ms.MinimumSize = fmt.Sprintf("%dM", store.MinimumSize)
+		}
+		marshaled[name] = ms
+	}
+	return marshaled
+}
+
+type marshaledDevice struct {
+	Description string     `yaml:"description,omitempty"`
+	Type        DeviceType `yaml:"type"`
+	CountMin    int64      `yaml:"countmin"`
+	CountMax    int64      `yaml:"countmax"`
+}
+
+func marshaledDevices(devices map[string]Device) map[string]marshaledDevice {
+	marshaled := make(map[string]marshaledDevice, len(devices))
+	for name, device := range devices {
+		marshaled[name] = marshaledDevice{
+			Description: device.Description,
+			Type:        device.Type,
+			CountMin:    device.CountMin,
+			CountMax:    device.CountMax,
+		}
+	}
+	return marshaled
+}
+
+type marshaledPayloadClass struct {
+	Type string `yaml:"type"`
+}
+
+func marshaledPayloadClasses(classes map[string]PayloadClass) map[string]marshaledPayloadClass {
+	marshaled := make(map[string]marshaledPayloadClass, len(classes))
+	for name, class := range classes {
+		marshaled[name] = marshaledPayloadClass{Type: class.Type}
+	}
+	return marshaled
+}
+
 func marshaledRelations(relations map[string]Relation) map[string]marshaledRelation {
 	marshaled := make(map[string]marshaledRelation)
 	for name, relation := range relations {
@@ -561,12 +814,13 @@ func (r marshaledRelation) MarshalYAML() (interface{}, error) {
 		noLimit = 0
 	}
 
-	if !r.Optional && r.Limit == noLimit && r.Scope == ScopeGlobal {
+	if !r.Optional && r.Limit == noLimit && r.Scope == ScopeGlobal && r.Versions.IsZero() {
 		// All attributes are default, so use the simple string form of the relation.
 		return r.Interface, nil
 	}
 	mr := struct {
 		Interface string        `yaml:"interface"`
+		Versions  []int         `yaml:"versions,omitempty"`
 		Limit     *int          `yaml:"limit,omitempty"`
 		Optional  bool          `yaml:"optional,omitempty"`
 		Scope     RelationScope `yaml:"scope,omitempty"`
@@ -574,6 +828,11 @@ func (r marshaledRelation) MarshalYAML() (interface{}, error) {
 		Interface: r.Interface,
 		Optional:  r.Optional,
 	}
+	if !r.Versions.IsZero() {
+		for v := r.Versions.Min; v <= r.Versions.Max; v++ {
+			mr.Versions = append(mr.Versions, v)
+		}
+	}
 	if r.Limit != noLimit {
 		mr.Limit = &r.Limit
 	}
@@ -618,6 +877,9 @@ func (meta Meta) Check() error {
 			if names[name] {
 				return fmt.Errorf("charm %q using a duplicated relation name: %q", meta.Name, name)
 			}
+			if err := rel.Versions.Validate(); err != nil {
+				return fmt.Errorf("charm %q relation %q: %v", meta.Name, name, err)
+			}
 			names[name] = true
 		}
 		return nil
@@ -659,6 +921,11 @@ func (meta Meta) Check() error {
 			return fmt.Errorf("charm %q declares invalid series: %q", meta.Name, series)
 		}
 	}
+	for _, base := range meta.Bases {
+		if err := base.Validate(); err != nil {
+			return fmt.Errorf("charm %q declares invalid base: %q", meta.Name, base)
+		}
+	}
 
 	names = make(map[string]bool)
 	for name, store := range meta.Storage {
@@ -715,6 +982,16 @@ func (meta Meta) Check() error {
 		}
 	}
 
+	if err := meta.Maturity.Validate(); err != nil {
+		return fmt.Errorf("charm %q has invalid maturity: %v", meta.Name, err)
+	}
+
+	if meta.ForkedFrom != "" {
+		if _, err := ParseURL(meta.ForkedFrom); err != nil {
+			return fmt.Errorf("charm %q has invalid forked-from URL: %v", meta.Name, err)
+		}
+	}
+
 	return nil
 }
 
@@ -749,6 +1026,9 @@ func parseRelations(relations interface{}, role RelationRole) map[string]Relatio
 			// the int range should be more than enough.
 			relation.Limit = int(relMap["limit"].(int64))
 		}
+		if versions, ok := relMap["versions"].(InterfaceVersions); ok {
+			relation.Versions = versions
+		}
 		result[name] = relation
 	}
 	return result
@@ -788,6 +1068,11 @@ func (m Meta) CombinedRelations() map[string]Relation {
 //       limit:
 //       optional: false
 //
+//   requires:
+//     db:
+//       interface: mysql
+//       versions: [1, 2]
+//
 // In all input cases, the output is the fully specified interface
 // representation as seen in the mysql interface description above.
 func ifaceExpander(limit interface{}) schema.Checker {
@@ -832,13 +1117,50 @@ var ifaceSchema = schema.FieldMap(
 		"limit":     schema.OneOf(schema.Const(nil), schema.Int()),
 		"scope":     schema.OneOf(schema.Const(string(ScopeGlobal)), schema.Const(string(ScopeContainer))),
 		"optional":  schema.Bool(),
+		"versions":  interfaceVersionsC{},
 	},
 	schema.Defaults{
 		"scope":    string(ScopeGlobal),
 		"optional": false,
+		"versions": schema.Omit,
 	},
 )
 
+// interfaceVersionsC coerces either a single version number
+// or a list of consecutive version numbers into an
+// InterfaceVersions value.
+type interfaceVersionsC struct{}
+
+func (c interfaceVersionsC) Coerce(v interface{}, path []string) (newv interface{}, err error) {
+	var vs []int
+	if n, err := schema.Int().Coerce(v, path); err == nil {
+		vs = []int{int(n.(int64))}
+	} else if _, ok := v.([]interface{}); ok {
+		l, err := schema.List(schema.Int()).Coerce(v, path)
+		if err != nil {
+			return nil, err
+		}
+		for _, n := range l.([]interface{}) {
+			vs = append(vs, int(n.(int64)))
+		}
+	} else {
+		return nil, fmt.Errorf("%s: expected int or list of ints, got %T(%#v)", strings.Join(path[1:], ""), v, v)
+	}
+	if len(vs) == 0 {
+		return nil, fmt.Errorf("%s: empty interface version list", strings.Join(path[1:], ""))
+	}
+	sort.Ints(vs)
+	if vs[0] < 1 {
+		return nil, fmt.Errorf("%s: invalid interface version %d", strings.Join(path[1:], ""), vs[0])
+	}
+	for i := 1; i < len(vs); i++ {
+		if vs[i] != vs[i-1]+1 {
+			return nil, fmt.Errorf("%s: interface versions %v are not consecutive", strings.Join(path[1:], ""), vs)
+		}
+	}
+	return InterfaceVersions{Min: vs[0], Max: vs[len(vs)-1]}, nil
+}
+
 func parseStorage(stores interface{}) map[string]Storage {
 	if stores == nil {
 		return nil
@@ -1029,12 +1351,16 @@ var charmSchema = schema.FieldMap(
 		"categories":       schema.List(schema.String()),
 		"tags":             schema.List(schema.String()),
 		"series":           schema.List(schema.String()),
+		"bases":            schema.List(schema.String()),
 		"storage":          schema.StringMap(storageSchema),
 		"devices":          schema.StringMap(deviceSchema),
 		"payloads":         schema.StringMap(payloadClassSchema),
 		"resources":        schema.StringMap(resourceSchema),
 		"terms":            schema.List(schema.String()),
 		"min-juju-version": schema.String(),
+		"maturity":         schema.String(),
+		"stability":        schema.String(),
+		"forked-from":      schema.String(),
 	},
 	schema.Defaults{
 		"provides":         schema.Omit,
@@ -1047,11 +1373,15 @@ var charmSchema = schema.FieldMap(
 		"categories":       schema.Omit,
 		"tags":             schema.Omit,
 		"series":           schema.Omit,
+		"bases":            schema.Omit,
 		"storage":          schema.Omit,
 		"devices":          schema.Omit,
 		"payloads":         schema.Omit,
 		"resources":        schema.Omit,
 		"terms":            schema.Omit,
 		"min-juju-version": schema.Omit,
+		"maturity":         schema.Omit,
+		"stability":        schema.Omit,
+		"forked-from":      schema.Omit,
 	},
 )
@@ -12,6 +12,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/juju/loggo"
 	jc "github.com/juju/testing/checkers"
 	"github.com/juju/version"
 	gc "gopkg.in/check.v1"
@@ -406,6 +407,69 @@ func (s *MetaSuite) TestParseMetaRelations(c *gc.C) {
 	c.Assert(meta.Peers, gc.IsNil)
 }
 
+func (s *MetaSuite) TestParseMetaRelationVersions(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata + `
+provides:
+  server:
+    interface: mysql
+    versions: [2, 1, 3]
+requires:
+  cache:
+    interface: memcache
+    versions: 2
+  logs: syslog
+`))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Provides["server"].Versions, gc.Equals, charm.InterfaceVersions{Min: 1, Max: 3})
+	c.Assert(meta.Requires["cache"].Versions, gc.Equals, charm.InterfaceVersions{Min: 2, Max: 2})
+	c.Assert(meta.Requires["logs"].Versions.IsZero(), jc.IsTrue)
+}
+
+var relationVersionsErrorTests = []struct {
+	versions    string
+	expectError string
+}{{
+	versions:    "[1, 3]",
+	expectError: `metadata: provides.server.versions: interface versions \[1 3\] are not consecutive`,
+}, {
+	versions:    "[]",
+	expectError: `metadata: provides.server.versions: empty interface version list`,
+}, {
+	versions:    "0",
+	expectError: `metadata: provides.server.versions: invalid interface version 0`,
+}, {
+	versions:    "foo",
+	expectError: `metadata: provides.server.versions: expected int or list of ints, got string\("foo"\)`,
+}, {
+	versions:    "[1, foo]",
+	expectError: `metadata: provides.server.versions\[1\]: expected int, got string\("foo"\)`,
+}}
+
+func (s *MetaSuite) TestParseMetaRelationVersionsErrors(c *gc.C) {
+	for i, test := range relationVersionsErrorTests {
+		c.Logf("test %d: %s", i, test.versions)
+		_, err := charm.ReadMeta(strings.NewReader(dummyMetadata + `
+provides:
+  server:
+    interface: mysql
+    versions: ` + test.versions + "\n"))
+		c.Check(err, gc.ErrorMatches, test.expectError)
+	}
+}
+
+func (s *MetaSuite) TestRelationCanRelateTo(c *gc.C) {
+	v := func(min, max int) charm.Relation {
+		return charm.Relation{
+			Interface: "mysql",
+			Versions:  charm.InterfaceVersions{Min: min, Max: max},
+		}
+	}
+	c.Assert(v(1, 2).CanRelateTo(v(2, 3)), jc.IsTrue)
+	c.Assert(v(1, 2).CanRelateTo(v(3, 4)), jc.IsFalse)
+	c.Assert(v(0, 0).CanRelateTo(v(3, 4)), jc.IsTrue)
+	c.Assert(v(1, 2).CanRelateTo(charm.Relation{Interface: "pgsql"}), jc.IsFalse)
+}
+
 func (s *MetaSuite) TestCombinedRelations(c *gc.C) {
 	meta, err := charm.ReadMeta(repoMeta(c, "riak"))
 	c.Assert(err, gc.IsNil)
@@ -530,6 +594,64 @@ func (s *MetaSuite) TestSeries(c *gc.C) {
 	c.Assert(meta.Series, gc.DeepEquals, []string{"precise", "trusty", "plan9"})
 }
 
+func (s *MetaSuite) TestBases(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata + "\nbases: [ubuntu/20.04, centos/7]\n"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Bases, jc.DeepEquals, []charm.Base{
+		{Name: "ubuntu", Channel: "20.04"},
+		{Name: "centos", Channel: "7"},
+	})
+}
+
+func (s *MetaSuite) TestMaturity(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata))
+	c.Assert(err, gc.IsNil)
+	c.Assert(meta.Maturity, gc.Equals, charm.Maturity(""))
+
+	meta, err = charm.ReadMeta(strings.NewReader(dummyMetadata + "\nmaturity: beta"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(meta.Maturity, gc.Equals, charm.MaturityBeta)
+
+	meta, err = charm.ReadMeta(strings.NewReader(dummyMetadata + "\nstability: experimental"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(meta.Maturity, gc.Equals, charm.MaturityExperimental)
+
+	meta, err = charm.ReadMeta(strings.NewReader(dummyMetadata + "\nmaturity: stable\nstability: stable"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(meta.Maturity, gc.Equals, charm.MaturityStable)
+}
+
+func (s *MetaSuite) TestInvalidMaturity(c *gc.C) {
+	_, err := charm.ReadMeta(strings.NewReader(dummyMetadata + "\nmaturity: alpha"))
+	c.Assert(err, gc.ErrorMatches, `charm "a" has invalid maturity: maturity "alpha" not valid`)
+
+	_, err = charm.ReadMeta(strings.NewReader(dummyMetadata + "\nmaturity: beta\nstability: stable"))
+	c.Assert(err, gc.ErrorMatches, `maturity "beta" does not match stability "stable"`)
+}
+
+func (s *MetaSuite) TestForkedFrom(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata + "\nforked-from: cs:~charmers/mysql-55"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(meta.ForkedFrom, gc.Equals, "cs:~charmers/mysql-55")
+
+	_, err = charm.ReadMeta(strings.NewReader(dummyMetadata + "\nforked-from: cs:~bad~user/mysql"))
+	c.Assert(err, gc.ErrorMatches, `charm "a" has invalid forked-from URL: .*`)
+}
+
+func (s *MetaSuite) TestInvalidBases(c *gc.C) {
+	for _, base := range []string{"ubuntu", "ubuntu/", "/20.04", "Ubuntu/20.04", "ubuntu/20.04/stable"} {
+		_, err := charm.ReadMeta(strings.NewReader(
+			fmt.Sprintf("%s\nbases:\n    - %q\n", dummyMetadata, base)))
+		c.Check(err, gc.ErrorMatches, `invalid bases: base ".*" not valid`)
+	}
+	err := (&charm.Meta{
+		Name:    "a",
+		Summary: "b",
+		Bases:   []charm.Base{{Name: "ubuntu"}},
+	}).Check()
+	c.Check(err, gc.ErrorMatches, `charm "a" declares invalid base: "ubuntu/"`)
+}
+
 func (s *MetaSuite) TestInvalidSeries(c *gc.C) {
 	for _, seriesName := range []string{"pre-c1se", "pre^cise", "cp/m", "OpenVMS"} {
 		_, err := charm.ReadMeta(strings.NewReader(
@@ -564,6 +686,65 @@ func (s *MetaSuite) TestInvalidMinJujuVersion(c *gc.C) {
 	c.Check(err, gc.ErrorMatches, `invalid min-juju-version: invalid version "invalid-version"`)
 }
 
+var metaDataWithDuplicateKeys = `
+name: dup
+summary: first summary
+description: a charm with duplicated keys
+summary: second summary
+provides:
+  website: http
+  website: https
+`
+
+func (s *MetaSuite) TestReadMetaDuplicateKeys(c *gc.C) {
+	var tw loggo.TestWriter
+	err := loggo.RegisterWriter("duplicate-keys-test", &tw, loggo.WARNING)
+	c.Assert(err, jc.ErrorIsNil)
+	defer loggo.RemoveWriter("duplicate-keys-test")
+
+	meta, err := charm.ReadMeta(strings.NewReader(metaDataWithDuplicateKeys))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Summary, gc.Equals, "second summary")
+	c.Assert(meta.Provides["website"].Interface, gc.Equals, "https")
+	c.Assert(tw.Log(), jc.LogMatches, []jc.SimpleMessage{{
+		Level:   loggo.WARNING,
+		Message: `metadata: duplicate key found: line 5: key "summary" already set in map`,
+	}, {
+		Level:   loggo.WARNING,
+		Message: `metadata: duplicate key found: line 8: key "website" already set in map`,
+	}})
+}
+
+func (s *MetaSuite) TestReadMetaStrictDuplicateKeys(c *gc.C) {
+	_, err := charm.ReadMetaStrict(strings.NewReader(metaDataWithDuplicateKeys))
+	c.Assert(err, gc.ErrorMatches, `metadata: duplicate keys: line 5: key "summary" already set in map; line 8: key "website" already set in map`)
+	c.Assert(charm.IsDuplicateKeysError(err), jc.IsTrue)
+
+	meta, err := charm.ReadMetaStrict(strings.NewReader(dummyMetadata))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Name, gc.Equals, "a")
+}
+
+func (s *MetaSuite) TestReadMetaWithBOM(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader("\ufeff" + dummyMetadata))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.Name, gc.Equals, "a")
+}
+
+func (s *MetaSuite) TestReadMetaInvalidEncoding(c *gc.C) {
+	_, err := charm.ReadMeta(strings.NewReader("name: caf\xe9\nsummary: b\ndescription: c\n"))
+	c.Assert(err, gc.ErrorMatches, `metadata.yaml: invalid UTF-8 at byte offset 9 \(files must be UTF-8 encoded\)`)
+	c.Assert(charm.IsEncodingError(err), jc.IsTrue)
+
+	// UTF-16 is rejected at the byte order mark.
+	_, err = charm.ReadMeta(strings.NewReader("\xff\xfen\x00a\x00"))
+	c.Assert(err, jc.DeepEquals, &charm.EncodingError{File: "metadata.yaml", Offset: 0})
+
+	// The offset counts the byte order mark.
+	_, err = charm.ReadMeta(strings.NewReader("\ufeffname: caf\xe9\n"))
+	c.Assert(err, jc.DeepEquals, &charm.EncodingError{File: "metadata.yaml", Offset: 12})
+}
+
 func (s *MetaSuite) TestNoMinJujuVersion(c *gc.C) {
 	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata))
 	c.Assert(err, jc.ErrorIsNil)
@@ -724,6 +905,48 @@ func (s *MetaSuite) TestMetaHooks(c *gc.C) {
 	c.Assert(hooks, jc.DeepEquals, expectedHooks)
 }
 
+func (s *MetaSuite) TestIsRelationHook(c *gc.C) {
+	meta, err := charm.ReadMeta(repoMeta(c, "wordpress"))
+	c.Assert(err, gc.IsNil)
+	c.Assert(meta.IsRelationHook("db-relation-joined"), jc.IsTrue)
+	c.Assert(meta.IsRelationHook("url-relation-broken"), jc.IsTrue)
+	c.Assert(meta.IsRelationHook("unknown-relation-joined"), jc.IsFalse)
+	c.Assert(meta.IsRelationHook("relation-joined"), jc.IsFalse)
+	c.Assert(meta.IsRelationHook("install"), jc.IsFalse)
+}
+
+func (s *MetaSuite) TestValidHookNames(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata + `
+provides:
+    website: http
+storage:
+    data:
+        type: filesystem
+`))
+	c.Assert(err, gc.IsNil)
+	c.Assert(meta.ValidHookNames(), jc.DeepEquals, []string{
+		"collect-metrics",
+		"config-changed",
+		"data-storage-attached",
+		"data-storage-detaching",
+		"install",
+		"leader-deposed",
+		"leader-elected",
+		"leader-settings-changed",
+		"meter-status-changed",
+		"post-series-upgrade",
+		"pre-series-upgrade",
+		"start",
+		"stop",
+		"update-status",
+		"upgrade-charm",
+		"website-relation-broken",
+		"website-relation-changed",
+		"website-relation-departed",
+		"website-relation-joined",
+	})
+}
+
 func (s *MetaSuite) TestCodecRoundTripEmpty(c *gc.C) {
 	for i, codec := range codecs {
 		c.Logf("codec %d", i)
@@ -876,6 +1099,9 @@ requires:
         optional: true
         scope: container
         limit: 3
+    requireVersioned:
+        interface: versionedinterface
+        versions: [2, 3]
 peers:
     peerSimple: someinterface
     peerLessSimple:
@@ -888,6 +1114,39 @@ categories: [c1, c1]
 tags: [t1, t2]
 series:
     - someseries
+bases:
+    - ubuntu/20.04
+    - centos/7
+maturity: beta
+forked-from: cs:~charmers/mysql-55
+storage:
+    data:
+        type: filesystem
+        description: the data
+        location: /srv/data
+        multiple:
+            range: 1-3
+        minimum-size: 1G
+        properties: [transient]
+    logs:
+        type: block
+        shared: true
+        read-only: true
+        multiple:
+            range: 2+
+    cache:
+        type: block
+        multiple:
+            range: 2
+devices:
+    gpu:
+        type: nvidia.com/gpu
+        description: a gpu
+        countmin: 1
+        countmax: 2
+payloads:
+    monitor:
+        type: docker
 resources:
     foo:
         description: 'a description'
@@ -80,6 +80,9 @@ func ReadMetrics(r io.Reader) (*Metrics, error) {
 	if err != nil {
 		return nil, err
 	}
+	if data, err = normalizeYAML("metrics", data); err != nil {
+		return nil, err
+	}
 	var metrics Metrics
 	if err := goyaml.Unmarshal(data, &metrics); err != nil {
 		return nil, err
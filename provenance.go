@@ -0,0 +1,39 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"github.com/juju/errors"
+)
+
+// maxForkChain holds the maximum number of upstream
+// charms followed by ForkChain.
+const maxForkChain = 100
+
+// ForkChain returns the URLs of the charms that ch was derived from,
+// nearest first, by following the forked-from metadata of each charm
+// in turn. The get function is called to fetch each upstream charm,
+// typically from a charm repository.
+func ForkChain(ch Charm, get func(*URL) (Charm, error)) ([]*URL, error) {
+	var chain []*URL
+	seen := make(map[string]bool)
+	for ch.Meta().ForkedFrom != "" {
+		curl, err := ParseURL(ch.Meta().ForkedFrom)
+		if err != nil {
+			return chain, errors.Annotatef(err, "charm %q has invalid forked-from URL", ch.Meta().Name)
+		}
+		if seen[curl.String()] {
+			return chain, errors.Errorf("fork chain loops back to %q", curl)
+		}
+		if len(chain) >= maxForkChain {
+			return chain, errors.Errorf("fork chain longer than %d charms", maxForkChain)
+		}
+		seen[curl.String()] = true
+		chain = append(chain, curl)
+		if ch, err = get(curl); err != nil {
+			return chain, errors.Annotatef(err, "cannot get upstream charm %q", curl)
+		}
+	}
+	return chain, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"github.com/juju/errors"
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type ProvenanceSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&ProvenanceSuite{})
+
+func forkedCharm(name, forkedFrom string) charm.Charm {
+	return testCharmImpl{
+		meta: &charm.Meta{
+			Name:       name,
+			ForkedFrom: forkedFrom,
+		},
+	}
+}
+
+func (s *ProvenanceSuite) TestForkChain(c *gc.C) {
+	upstream := map[string]charm.Charm{
+		"cs:~bob/mysql-3":       forkedCharm("mysql", "cs:~charmers/mysql-55"),
+		"cs:~charmers/mysql-55": forkedCharm("mysql", ""),
+	}
+	get := func(curl *charm.URL) (charm.Charm, error) {
+		if ch, ok := upstream[curl.String()]; ok {
+			return ch, nil
+		}
+		return nil, errors.NotFoundf("charm %q", curl)
+	}
+
+	chain, err := charm.ForkChain(forkedCharm("mysql", "cs:~bob/mysql-3"), get)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(chain, jc.DeepEquals, []*charm.URL{
+		charm.MustParseURL("cs:~bob/mysql-3"),
+		charm.MustParseURL("cs:~charmers/mysql-55"),
+	})
+
+	chain, err = charm.ForkChain(forkedCharm("mysql", ""), get)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(chain, gc.HasLen, 0)
+
+	chain, err = charm.ForkChain(forkedCharm("mysql", "cs:~alice/mysql-1"), get)
+	c.Assert(err, gc.ErrorMatches, `cannot get upstream charm "cs:~alice/mysql-1": charm "cs:~alice/mysql-1" not found`)
+	c.Assert(chain, gc.HasLen, 1)
+}
+
+func (s *ProvenanceSuite) TestForkChainLoop(c *gc.C) {
+	upstream := map[string]charm.Charm{
+		"cs:~bob/mysql-3":   forkedCharm("mysql", "cs:~alice/mysql-1"),
+		"cs:~alice/mysql-1": forkedCharm("mysql", "cs:~bob/mysql-3"),
+	}
+	get := func(curl *charm.URL) (charm.Charm, error) {
+		return upstream[curl.String()], nil
+	}
+	_, err := charm.ForkChain(forkedCharm("mysql", "cs:~bob/mysql-3"), get)
+	c.Assert(err, gc.ErrorMatches, `fork chain loops back to "cs:~bob/mysql-3"`)
+}
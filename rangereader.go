@@ -0,0 +1,231 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+const (
+	// rangeChunkSize holds the number of bytes fetched
+	// by each range request.
+	rangeChunkSize = 64 * 1024
+
+	// rangeMaxChunks holds the maximum number of chunks
+	// cached by an HTTPRangeReader.
+	rangeMaxChunks = 16
+)
+
+// HTTPRangeReader is an io.ReaderAt that reads a remote file
+// using HTTP range requests, so that only the parts of the file
+// that are actually read are downloaded. Recently read parts
+// of the file are cached in memory. It is safe to call ReadAt
+// concurrently; different parts of the file are then fetched
+// in parallel.
+type HTTPRangeReader struct {
+	client *http.Client
+	url    string
+	size   int64
+
+	// validator holds the strong ETag or the Last-Modified time
+	// returned for the file by the HEAD request, if any. It is
+	// sent with each range request so that the server refuses
+	// to return parts of a different version of the file.
+	validator string
+
+	mu     sync.Mutex
+	chunks map[int64][]byte
+	// order holds the indexes of the cached chunks,
+	// least recently fetched first.
+	order []int64
+	// fetching holds the chunks currently being fetched,
+	// so that concurrent reads of a chunk fetch it only once.
+	fetching map[int64]*chunkFetch
+}
+
+// chunkFetch holds a chunk that is being fetched.
+type chunkFetch struct {
+	// done is closed when the fetch has completed
+	// and data and err have been set.
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// NewHTTPRangeReader returns a reader for the file at the given URL.
+// It sends a HEAD request to find the size and version of the file,
+// and returns an error if the server does not support range requests.
+// If the file changes after that, later reads fail rather than mixing
+// the contents of the two versions. If client is nil,
+// http.DefaultClient is used.
+func NewHTTPRangeReader(client *http.Client, url string) (*HTTPRangeReader, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Head(url)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.Errorf("cannot get %q: %s", url, resp.Status)
+	}
+	if resp.Header.Get("Accept-Ranges") != "bytes" {
+		return nil, errors.Errorf("cannot get %q: server does not support range requests", url)
+	}
+	if resp.ContentLength < 0 {
+		return nil, errors.Errorf("cannot get %q: unknown content length", url)
+	}
+	// Weak ETags cannot be used with If-Range.
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		validator = resp.Header.Get("Last-Modified")
+	}
+	return &HTTPRangeReader{
+		client:    client,
+		url:       url,
+		size:      resp.ContentLength,
+		validator: validator,
+		chunks:    make(map[int64][]byte),
+		fetching:  make(map[int64]*chunkFetch),
+	}, nil
+}
+
+// Size returns the size of the remote file.
+func (r *HTTPRangeReader) Size() int64 {
+	return r.size
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *HTTPRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.Errorf("negative offset %d", off)
+	}
+	n := 0
+	for n < len(p) {
+		pos := off + int64(n)
+		if pos >= r.size {
+			return n, io.EOF
+		}
+		chunk, err := r.chunk(pos / rangeChunkSize)
+		if err != nil {
+			return n, errors.Trace(err)
+		}
+		n += copy(p[n:], chunk[pos%rangeChunkSize:])
+	}
+	return n, nil
+}
+
+// chunk returns the contents of the chunk with the given index,
+// fetching it if it is not in the cache. The lock is not held
+// while the chunk is fetched.
+func (r *HTTPRangeReader) chunk(index int64) ([]byte, error) {
+	r.mu.Lock()
+	if chunk, ok := r.chunks[index]; ok {
+		r.mu.Unlock()
+		return chunk, nil
+	}
+	if f, ok := r.fetching[index]; ok {
+		r.mu.Unlock()
+		<-f.done
+		return f.data, f.err
+	}
+	f := &chunkFetch{
+		done: make(chan struct{}),
+	}
+	r.fetching[index] = f
+	r.mu.Unlock()
+
+	start := index * rangeChunkSize
+	end := start + rangeChunkSize
+	if end > r.size {
+		end = r.size
+	}
+	f.data, f.err = r.fetch(start, end)
+
+	r.mu.Lock()
+	delete(r.fetching, index)
+	if f.err == nil {
+		if len(r.order) >= rangeMaxChunks {
+			delete(r.chunks, r.order[0])
+			r.order = r.order[1:]
+		}
+		r.chunks[index] = f.data
+		r.order = append(r.order, index)
+	}
+	r.mu.Unlock()
+	close(f.done)
+	return f.data, f.err
+}
+
+// fetch fetches the bytes from start up to but
+// not including end.
+func (r *HTTPRangeReader) fetch(start, end int64) ([]byte, error) {
+	req, err := http.NewRequest("GET", r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", start, end-1))
+	if r.validator != "" {
+		req.Header.Set("If-Range", r.validator)
+	}
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK && r.validator != "":
+		// The server ignored the range because the
+		// file no longer matches the validator.
+		return nil, errors.Errorf("cannot get bytes %d-%d of %q: file has changed", start, end-1, r.url)
+	case resp.StatusCode != http.StatusPartialContent:
+		return nil, errors.Errorf("cannot get bytes %d-%d of %q: %s", start, end-1, r.url, resp.Status)
+	}
+	if err := r.checkContentRange(resp.Header.Get("Content-Range"), start, end); err != nil {
+		return nil, errors.Annotatef(err, "cannot get bytes %d-%d of %q", start, end-1, r.url)
+	}
+	data := make([]byte, end-start)
+	if _, err := io.ReadFull(resp.Body, data); err != nil {
+		return nil, errors.Annotatef(err, "cannot read bytes %d-%d of %q", start, end-1, r.url)
+	}
+	return data, nil
+}
+
+// checkContentRange checks that the given Content-Range header
+// value describes the bytes from start up to but not including
+// end of a file of the expected size.
+func (r *HTTPRangeReader) checkContentRange(contentRange string, start, end int64) error {
+	var gotStart, gotEnd, gotSize int64
+	if _, err := fmt.Sscanf(contentRange, "bytes %d-%d/%d", &gotStart, &gotEnd, &gotSize); err != nil {
+		return errors.Errorf("invalid Content-Range %q", contentRange)
+	}
+	if gotStart != start || gotEnd != end-1 {
+		return errors.Errorf("server returned wrong range %q", contentRange)
+	}
+	if gotSize != r.size {
+		return errors.Errorf("file has changed: size is now %d", gotSize)
+	}
+	return nil
+}
+
+// ReadCharmArchiveFromURL returns a CharmArchive that reads the
+// charm archive at the given URL using HTTP range requests. Only
+// the parts of the archive needed by the methods called on the
+// returned CharmArchive are downloaded; for example, reading the
+// charm's metadata does not require the whole archive. If client
+// is nil, http.DefaultClient is used.
+func ReadCharmArchiveFromURL(client *http.Client, url string) (*CharmArchive, error) {
+	r, err := NewHTTPRangeReader(client, url)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	return ReadCharmArchiveFromReader(r, r.Size())
+}
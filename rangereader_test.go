@@ -0,0 +1,235 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type RangeReaderSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&RangeReaderSuite{})
+
+// serveArchive serves data, recording the number
+// of body bytes written in *sent.
+func serveArchive(data []byte, sent *int64) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		http.ServeContent(countingWriter{w, sent}, req, "archive.charm", time.Time{}, bytes.NewReader(data))
+	}))
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+	n *int64
+}
+
+func (w countingWriter) Write(buf []byte) (int, error) {
+	atomic.AddInt64(w.n, int64(len(buf)))
+	return w.ResponseWriter.Write(buf)
+}
+
+func (s *RangeReaderSuite) TestReadCharmArchiveFromURL(c *gc.C) {
+	// Add a large incompressible file so that downloading the
+	// whole archive would be noticeable.
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	big := make([]byte, 4*1024*1024)
+	rand.New(rand.NewSource(0)).Read(big)
+	err := ioutil.WriteFile(filepath.Join(path, "big"), big, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	var buf bytes.Buffer
+	err = dir.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+
+	var sent int64
+	srv := serveArchive(buf.Bytes(), &sent)
+	defer srv.Close()
+
+	archive, err := charm.ReadCharmArchiveFromURL(nil, srv.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(archive.Meta(), jc.DeepEquals, dir.Meta())
+	c.Assert(archive.Config(), jc.DeepEquals, dir.Config())
+	c.Assert(archive.Revision(), gc.Equals, dir.Revision())
+	c.Assert(atomic.LoadInt64(&sent) < int64(buf.Len())/10, jc.IsTrue, gc.Commentf("sent %d of %d bytes", sent, buf.Len()))
+
+	// Members can still be read in full.
+	expandDir := c.MkDir()
+	err = archive.ExpandTo(expandDir)
+	c.Assert(err, jc.ErrorIsNil)
+	data, err := ioutil.ReadFile(filepath.Join(expandDir, "big"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(bytes.Equal(data, big), jc.IsTrue)
+}
+
+func (s *RangeReaderSuite) TestReadAt(c *gc.C) {
+	data := make([]byte, 200*1024)
+	rand.New(rand.NewSource(0)).Read(data)
+	var sent int64
+	srv := serveArchive(data, &sent)
+	defer srv.Close()
+
+	r, err := charm.NewHTTPRangeReader(nil, srv.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(r.Size(), gc.Equals, int64(len(data)))
+
+	// Read across a chunk boundary.
+	p := make([]byte, 1000)
+	n, err := r.ReadAt(p, 64*1024-500)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(n, gc.Equals, 1000)
+	c.Assert(p, jc.DeepEquals, data[64*1024-500:64*1024+500])
+
+	// Read past the end.
+	n, err = r.ReadAt(p, int64(len(data))-10)
+	c.Assert(err, gc.Equals, io.EOF)
+	c.Assert(n, gc.Equals, 10)
+	c.Assert(p[:n], jc.DeepEquals, data[len(data)-10:])
+}
+
+func (s *RangeReaderSuite) TestNoRangeSupport(c *gc.C) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Write([]byte("no ranges here"))
+	}))
+	defer srv.Close()
+	_, err := charm.ReadCharmArchiveFromURL(nil, srv.URL)
+	c.Assert(err, gc.ErrorMatches, `cannot get ".*": server does not support range requests`)
+}
+
+func (s *RangeReaderSuite) TestNotFound(c *gc.C) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+	_, err := charm.ReadCharmArchiveFromURL(nil, srv.URL)
+	c.Assert(err, gc.ErrorMatches, `cannot get ".*": 404 Not Found`)
+}
+
+func (s *RangeReaderSuite) TestFileChanged(c *gc.C) {
+	data := make([]byte, 200*1024)
+	rand.New(rand.NewSource(0)).Read(data)
+	var version int32 = 1
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		v := atomic.LoadInt32(&version)
+		w.Header().Set("ETag", fmt.Sprintf(`"v%d"`, v))
+		content := data
+		if v > 1 {
+			content = bytes.ToUpper(data)
+		}
+		http.ServeContent(w, req, "archive.charm", time.Time{}, bytes.NewReader(content))
+	}))
+	defer srv.Close()
+
+	r, err := charm.NewHTTPRangeReader(nil, srv.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	p := make([]byte, 10)
+	_, err = r.ReadAt(p, 0)
+	c.Assert(err, jc.ErrorIsNil)
+
+	atomic.StoreInt32(&version, 2)
+	_, err = r.ReadAt(p, 100*1024)
+	c.Assert(err, gc.ErrorMatches, `cannot get bytes 65536-131071 of ".*": file has changed`)
+}
+
+func (s *RangeReaderSuite) TestBadContentRange(c *gc.C) {
+	for i, test := range []struct {
+		contentRange string
+		expectError  string
+	}{{
+		contentRange: "bytes 10-19/1000",
+		expectError:  `cannot get bytes 0-999 of ".*": server returned wrong range "bytes 10-19/1000"`,
+	}, {
+		contentRange: "bytes 0-999/2000",
+		expectError:  `cannot get bytes 0-999 of ".*": file has changed: size is now 2000`,
+	}, {
+		contentRange: "",
+		expectError:  `cannot get bytes 0-999 of ".*": invalid Content-Range ""`,
+	}} {
+		c.Logf("test %d: %q", i, test.contentRange)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", "1000")
+			if req.Method == "HEAD" {
+				return
+			}
+			w.Header().Set("Content-Range", test.contentRange)
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(make([]byte, 1000))
+		}))
+		r, err := charm.NewHTTPRangeReader(nil, srv.URL)
+		c.Assert(err, jc.ErrorIsNil)
+		_, err = r.ReadAt(make([]byte, 10), 0)
+		c.Check(err, gc.ErrorMatches, test.expectError)
+		srv.Close()
+	}
+}
+
+func (s *RangeReaderSuite) TestConcurrentReads(c *gc.C) {
+	data := make([]byte, 200*1024)
+	rand.New(rand.NewSource(0)).Read(data)
+	var gets int32
+	arrived := make(chan string, 3)
+	release := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method == "GET" {
+			atomic.AddInt32(&gets, 1)
+			arrived <- req.Header.Get("Range")
+			<-release
+		}
+		http.ServeContent(w, req, "archive.charm", time.Time{}, bytes.NewReader(data))
+	}))
+	defer srv.Close()
+
+	r, err := charm.NewHTTPRangeReader(nil, srv.URL)
+	c.Assert(err, jc.ErrorIsNil)
+	type result struct {
+		off int64
+		p   []byte
+		err error
+	}
+	results := make(chan result)
+	read := func(off int64) {
+		p := make([]byte, 10)
+		_, err := r.ReadAt(p, off)
+		results <- result{off, p, err}
+	}
+	waitArrival := func(expect string) {
+		select {
+		case got := <-arrived:
+			c.Assert(got, gc.Equals, expect)
+		case <-time.After(10 * time.Second):
+			c.Fatalf("timed out waiting for request for %s", expect)
+		}
+	}
+
+	// A second chunk is fetched while the first is in flight.
+	go read(0)
+	waitArrival("bytes=0-65535")
+	go read(100 * 1024)
+	waitArrival("bytes=65536-131071")
+	// A read of a chunk that is being fetched waits for that fetch.
+	go read(20)
+	close(release)
+	for i := 0; i < 3; i++ {
+		res := <-results
+		c.Assert(res.err, jc.ErrorIsNil)
+		c.Assert(res.p, jc.DeepEquals, data[res.off:res.off+10])
+	}
+	c.Assert(atomic.LoadInt32(&gets), gc.Equals, int32(2))
+}
@@ -5,6 +5,12 @@ package charm
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
 
 	"github.com/juju/errors"
 	"github.com/juju/schema"
@@ -84,3 +90,128 @@ func parseResourceMeta(name string, data interface{}) (resource.Meta, error) {
 
 	return meta, nil
 }
+
+// attachedResourcesDir holds the directory, relative to the charm
+// root, that holds resource files attached to a charm directory.
+const attachedResourcesDir = "resources"
+
+// attachedMarker returns the name of the marker file, inside
+// attachedResourcesDir, recording that the named resource was
+// attached by AttachResource rather than shipped with the charm.
+func attachedMarker(name string) string {
+	return "." + name + ".attached"
+}
+
+// checkResourceName checks that the given resource name can be
+// used as a single file name inside attachedResourcesDir.
+func checkResourceName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsAny(name, `/\`) {
+		return errors.NotValidf("resource name %q", name)
+	}
+	return nil
+}
+
+// AttachResource writes the content of the given file resource, read
+// from r, into the charm directory at resources/<name>, so that the
+// charm and its resources may be kept together for offline use.
+// The resource must be declared in the charm metadata, and the
+// content must match res.Size and res.Fingerprint.
+//
+// Attached resources are not included when the charm directory
+// is archived; files under resources/ that were not attached
+// are archived as usual.
+func (dir *CharmDir) AttachResource(res resource.Resource, r io.Reader) error {
+	if err := checkResourceName(res.Name); err != nil {
+		return errors.Trace(err)
+	}
+	if err := res.Validate(); err != nil {
+		return errors.Annotatef(err, "invalid resource %q", res.Name)
+	}
+	declared, ok := dir.meta.Resources[res.Name]
+	if !ok {
+		return errors.NotFoundf("resource %q in charm metadata", res.Name)
+	}
+	if declared.Type != resource.TypeFile || res.Type != resource.TypeFile {
+		return errors.Errorf("resource %q is not a file resource", res.Name)
+	}
+	resDir := dir.join(attachedResourcesDir)
+	if err := os.MkdirAll(resDir, 0755); err != nil {
+		return errors.Trace(err)
+	}
+	f, err := ioutil.TempFile(resDir, ".attach-")
+	if err != nil {
+		return errors.Trace(err)
+	}
+	defer os.Remove(f.Name())
+	h := resource.NewFingerprintHash()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Annotatef(err, "cannot write resource %q", res.Name)
+	}
+	if size != res.Size {
+		return errors.Errorf("resource %q size mismatch: got %d bytes, expected %d", res.Name, size, res.Size)
+	}
+	if fp := h.Fingerprint(); fp.String() != res.Fingerprint.String() {
+		return errors.Errorf("resource %q fingerprint mismatch", res.Name)
+	}
+	if err := os.Rename(f.Name(), dir.join(attachedResourcesDir, res.Name)); err != nil {
+		return errors.Trace(err)
+	}
+	if err := ioutil.WriteFile(dir.join(attachedResourcesDir, attachedMarker(res.Name)), nil, 0644); err != nil {
+		return errors.Trace(err)
+	}
+	return nil
+}
+
+// AttachedResource returns the path of the named resource attached
+// to the charm directory by AttachResource. It returns an error
+// satisfying errors.IsNotFound if the resource is not attached.
+func (dir *CharmDir) AttachedResource(name string) (string, error) {
+	if err := checkResourceName(name); err != nil {
+		return "", errors.Trace(err)
+	}
+	if _, ok := dir.meta.Resources[name]; !ok {
+		return "", errors.NotFoundf("resource %q in charm metadata", name)
+	}
+	if _, err := os.Stat(dir.join(attachedResourcesDir, attachedMarker(name))); err != nil {
+		if os.IsNotExist(err) {
+			return "", errors.NotFoundf("attached resource %q", name)
+		}
+		return "", errors.Trace(err)
+	}
+	p := dir.join(attachedResourcesDir, name)
+	info, err := os.Stat(p)
+	if os.IsNotExist(err) || err == nil && !info.Mode().IsRegular() {
+		return "", errors.NotFoundf("attached resource %q", name)
+	}
+	if err != nil {
+		return "", errors.Trace(err)
+	}
+	return p, nil
+}
+
+// attachedResourcePaths returns the slash-separated paths, relative
+// to the charm root, of the resources declared by meta that have
+// been attached to the charm directory at root, together with
+// their marker files.
+func attachedResourcePaths(root string, meta *Meta) map[string]bool {
+	if len(meta.Resources) == 0 {
+		return nil
+	}
+	paths := make(map[string]bool)
+	for name := range meta.Resources {
+		if checkResourceName(name) != nil {
+			continue
+		}
+		marker := filepath.Join(root, attachedResourcesDir, attachedMarker(name))
+		if _, err := os.Stat(marker); err != nil {
+			continue
+		}
+		paths[path.Join(attachedResourcesDir, name)] = true
+		paths[path.Join(attachedResourcesDir, attachedMarker(name))] = true
+	}
+	return paths
+}
@@ -4,10 +4,18 @@
 package charm_test
 
 import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/juju/errors"
 	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 
 	"gopkg.in/juju/charm.v6"
+	"gopkg.in/juju/charm.v6/resource"
 )
 
 var _ = gc.Suite(&resourceSuite{})
@@ -75,3 +83,155 @@ func (s *resourceSuite) TestSchemaMissingComment(c *gc.C) {
 		"description": "",
 	})
 }
+
+func (s *resourceSuite) dirWithResources(c *gc.C) *charm.CharmDir {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	metadata, err := ioutil.ReadFile(filepath.Join(path, "metadata.yaml"))
+	c.Assert(err, jc.ErrorIsNil)
+	metadata = append(metadata, `
+resources:
+    data:
+        type: file
+        filename: data.tgz
+`...)
+	err = ioutil.WriteFile(filepath.Join(path, "metadata.yaml"), metadata, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+	return dir
+}
+
+func newFileResource(c *gc.C, name, content string) resource.Resource {
+	fp, err := resource.GenerateFingerprint(strings.NewReader(content))
+	c.Assert(err, jc.ErrorIsNil)
+	return resource.Resource{
+		Meta: resource.Meta{
+			Name: name,
+			Type: resource.TypeFile,
+			Path: name + ".tgz",
+		},
+		Origin:      resource.OriginUpload,
+		Fingerprint: fp,
+		Size:        int64(len(content)),
+	}
+}
+
+func (s *resourceSuite) TestAttachResource(c *gc.C) {
+	dir := s.dirWithResources(c)
+	_, err := dir.AttachedResource("data")
+	c.Assert(errors.IsNotFound(err), jc.IsTrue)
+
+	res := newFileResource(c, "data", "some data")
+	err = dir.AttachResource(res, strings.NewReader("some data"))
+	c.Assert(err, jc.ErrorIsNil)
+
+	path, err := dir.AttachedResource("data")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(path, gc.Equals, filepath.Join(dir.Path, "resources", "data"))
+	data, err := ioutil.ReadFile(path)
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(string(data), gc.Equals, "some data")
+
+	// The attached resource is left out of the archive.
+	var buf bytes.Buffer
+	err = dir.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	manifest, err := archive.Manifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(manifest.Contains("resources/data"), jc.IsFalse)
+	c.Assert(manifest.Contains("metadata.yaml"), jc.IsTrue)
+}
+
+func (s *resourceSuite) TestAttachResourceMismatch(c *gc.C) {
+	dir := s.dirWithResources(c)
+	res := newFileResource(c, "data", "some data")
+
+	err := dir.AttachResource(res, strings.NewReader("other data"))
+	c.Assert(err, gc.ErrorMatches, `resource "data" size mismatch: got 10 bytes, expected 9`)
+	err = dir.AttachResource(res, strings.NewReader("Some data"))
+	c.Assert(err, gc.ErrorMatches, `resource "data" fingerprint mismatch`)
+
+	_, err = dir.AttachedResource("data")
+	c.Assert(errors.IsNotFound(err), jc.IsTrue)
+	entries, err := ioutil.ReadDir(filepath.Join(dir.Path, "resources"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(entries, gc.HasLen, 0)
+}
+
+func (s *resourceSuite) TestAttachResourceUndeclared(c *gc.C) {
+	dir := s.dirWithResources(c)
+	err := dir.AttachResource(newFileResource(c, "other", "x"), strings.NewReader("x"))
+	c.Assert(err, gc.ErrorMatches, `resource "other" in charm metadata not found`)
+	_, err = os.Stat(filepath.Join(dir.Path, "resources"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+
+	_, err = dir.AttachedResource("other")
+	c.Assert(err, gc.ErrorMatches, `resource "other" in charm metadata not found`)
+}
+
+func (s *resourceSuite) TestAttachResourceBadName(c *gc.C) {
+	path := cloneDir(c, charmDirPath(c, "dummy"))
+	metadata, err := ioutil.ReadFile(filepath.Join(path, "metadata.yaml"))
+	c.Assert(err, jc.ErrorIsNil)
+	metadata = append(metadata, `
+resources:
+    ../../outside:
+        type: file
+        filename: data.tgz
+    ..:
+        type: file
+        filename: data.tgz
+`...)
+	err = ioutil.WriteFile(filepath.Join(path, "metadata.yaml"), metadata, 0644)
+	c.Assert(err, jc.ErrorIsNil)
+	dir, err := charm.ReadCharmDir(path)
+	c.Assert(err, jc.ErrorIsNil)
+
+	for _, name := range []string{"../../outside", "..", ".", "a/b", `a\b`} {
+		c.Logf("name %q", name)
+		err := dir.AttachResource(newFileResource(c, name, "x"), strings.NewReader("x"))
+		c.Assert(err, gc.ErrorMatches, `resource name ".*" not valid`)
+		c.Assert(errors.IsNotValid(err), jc.IsTrue)
+		_, err = dir.AttachedResource(name)
+		c.Assert(errors.IsNotValid(err), jc.IsTrue)
+	}
+	_, err = os.Stat(filepath.Join(path, "resources"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+	_, err = os.Stat(filepath.Join(path, "..", "outside"))
+	c.Assert(os.IsNotExist(err), jc.IsTrue)
+}
+
+func (s *resourceSuite) TestArchiveKeepsUnattachedResourceFiles(c *gc.C) {
+	dir := s.dirWithResources(c)
+	err := os.MkdirAll(filepath.Join(dir.Path, "resources"), 0755)
+	c.Assert(err, jc.ErrorIsNil)
+	err = ioutil.WriteFile(filepath.Join(dir.Path, "resources", "data"), []byte("shipped"), 0644)
+	c.Assert(err, jc.ErrorIsNil)
+
+	// A file that was not attached is part of the charm.
+	_, err = dir.AttachedResource("data")
+	c.Assert(errors.IsNotFound(err), jc.IsTrue)
+	var buf bytes.Buffer
+	err = dir.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err := charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	manifest, err := archive.Manifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(manifest.Contains("resources/data"), jc.IsTrue)
+
+	// Once attached, neither the file nor its marker is archived.
+	err = dir.AttachResource(newFileResource(c, "data", "some data"), strings.NewReader("some data"))
+	c.Assert(err, jc.ErrorIsNil)
+	buf.Reset()
+	err = dir.ArchiveTo(&buf)
+	c.Assert(err, jc.ErrorIsNil)
+	archive, err = charm.ReadCharmArchiveBytes(buf.Bytes())
+	c.Assert(err, jc.ErrorIsNil)
+	manifest, err = archive.Manifest()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(manifest.Contains("resources/data"), jc.IsFalse)
+	c.Assert(manifest.Contains("resources/.data.attached"), jc.IsFalse)
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+// categoryTags maps the legacy charm categories to the tags that
+// replaced them. Categories not mentioned here are carried over
+// as tags of the same name.
+var categoryTags = map[string]string{
+	"app-servers":  "app-servers",
+	"applications": "applications",
+	"cache-proxy":  "cache-proxy",
+	"databases":    "database",
+	"file-servers": "storage",
+	"misc":         "misc",
+}
+
+// tagCategories holds the inverse of categoryTags.
+var tagCategories = func() map[string]string {
+	m := make(map[string]string)
+	for category, tag := range categoryTags {
+		m[tag] = category
+	}
+	return m
+}()
+
+// CategoryTags returns a map from the legacy charm categories to
+// the tags that replaced them. Categories not mentioned in the map
+// are carried over as tags of the same name. The returned map is
+// a copy, so changing it does not affect AllTags or AllCategories.
+func CategoryTags() map[string]string {
+	m := make(map[string]string, len(categoryTags))
+	for category, tag := range categoryTags {
+		m[category] = tag
+	}
+	return m
+}
+
+// TagsSource describes where the tags and categories of a charm
+// were specified in its metadata.
+type TagsSource int
+
+const (
+	// NoTags indicates that the metadata specified
+	// neither tags nor categories.
+	NoTags TagsSource = iota
+
+	// FromTags indicates that the metadata specified
+	// only tags.
+	FromTags
+
+	// FromCategories indicates that the metadata specified
+	// only the legacy categories.
+	FromCategories
+
+	// FromBoth indicates that the metadata specified
+	// both tags and categories.
+	FromBoth
+)
+
+// TagsSource reports which of tags and categories were specified
+// in the charm metadata. Meta leaves both fields as they were read,
+// so writing the metadata back preserves its original form.
+func (m Meta) TagsSource() TagsSource {
+	switch {
+	case len(m.Tags) > 0 && len(m.Categories) > 0:
+		return FromBoth
+	case len(m.Tags) > 0:
+		return FromTags
+	case len(m.Categories) > 0:
+		return FromCategories
+	}
+	return NoTags
+}
+
+// AllTags returns the tags of the charm, including those derived
+// from its legacy categories by way of the CategoryTags mapping.
+func (m Meta) AllTags() []string {
+	tags := make([]string, 0, len(m.Tags)+len(m.Categories))
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		if !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, tag := range m.Tags {
+		add(tag)
+	}
+	for _, category := range m.Categories {
+		if tag, ok := categoryTags[category]; ok {
+			add(tag)
+		} else {
+			add(category)
+		}
+	}
+	return tags
+}
+
+// AllCategories returns the legacy categories of the charm,
+// including those corresponding to its tags by way of the
+// CategoryTags mapping. Tags with no corresponding category are
+// left out.
+func (m Meta) AllCategories() []string {
+	categories := make([]string, 0, len(m.Categories)+len(m.Tags))
+	seen := make(map[string]bool)
+	add := func(category string) {
+		if !seen[category] {
+			seen[category] = true
+			categories = append(categories, category)
+		}
+	}
+	for _, category := range m.Categories {
+		add(category)
+	}
+	for _, tag := range m.Tags {
+		if category, ok := tagCategories[tag]; ok {
+			add(category)
+		}
+	}
+	return categories
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"strings"
+
+	"github.com/juju/testing"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+	"gopkg.in/yaml.v2"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type TagsSuite struct {
+	testing.IsolationSuite
+}
+
+var _ = gc.Suite(&TagsSuite{})
+
+var tagsTests = []struct {
+	about            string
+	metadata         string
+	expectSource     charm.TagsSource
+	expectTags       []string
+	expectCategories []string
+}{{
+	about:            "neither",
+	metadata:         "",
+	expectSource:     charm.NoTags,
+	expectTags:       []string{},
+	expectCategories: []string{},
+}, {
+	about:            "categories only",
+	metadata:         "categories: [databases, misc, monitoring]",
+	expectSource:     charm.FromCategories,
+	expectTags:       []string{"database", "misc", "monitoring"},
+	expectCategories: []string{"databases", "misc", "monitoring"},
+}, {
+	about:            "tags only",
+	metadata:         "tags: [database, openstack, storage]",
+	expectSource:     charm.FromTags,
+	expectTags:       []string{"database", "openstack", "storage"},
+	expectCategories: []string{"databases", "file-servers"},
+}, {
+	about:            "both",
+	metadata:         "tags: [database, openstack]\ncategories: [databases, cache-proxy]",
+	expectSource:     charm.FromBoth,
+	expectTags:       []string{"database", "openstack", "cache-proxy"},
+	expectCategories: []string{"databases", "cache-proxy"},
+}}
+
+func (s *TagsSuite) TestTags(c *gc.C) {
+	for i, test := range tagsTests {
+		c.Logf("test %d: %s", i, test.about)
+		meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata + "\n" + test.metadata))
+		c.Assert(err, jc.ErrorIsNil)
+		c.Check(meta.TagsSource(), gc.Equals, test.expectSource)
+		c.Check(meta.AllTags(), jc.DeepEquals, test.expectTags)
+		c.Check(meta.AllCategories(), jc.DeepEquals, test.expectCategories)
+	}
+}
+
+func (s *TagsSuite) TestTagsRoundTrip(c *gc.C) {
+	meta, err := charm.ReadMeta(strings.NewReader(dummyMetadata + "\ncategories: [databases]"))
+	c.Assert(err, jc.ErrorIsNil)
+	data, err := yaml.Marshal(meta)
+	c.Assert(err, jc.ErrorIsNil)
+	meta, err = charm.ReadMeta(strings.NewReader(string(data)))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(meta.TagsSource(), gc.Equals, charm.FromCategories)
+	c.Assert(meta.Categories, jc.DeepEquals, []string{"databases"})
+	c.Assert(meta.Tags, gc.HasLen, 0)
+}
+
+func (s *TagsSuite) TestCategoryTagsUnique(c *gc.C) {
+	seen := make(map[string]string)
+	for category, tag := range charm.CategoryTags() {
+		other, ok := seen[tag]
+		c.Check(ok, jc.IsFalse, gc.Commentf("tag %q used for %q and %q", tag, category, other))
+		seen[tag] = category
+	}
+}
+
+func (s *TagsSuite) TestCategoryTagsCopy(c *gc.C) {
+	m := charm.CategoryTags()
+	m["databases"] = "something-else"
+	c.Assert(charm.CategoryTags()["databases"], gc.Equals, "database")
+
+	meta := charm.Meta{Categories: []string{"databases"}}
+	c.Assert(meta.AllTags(), jc.DeepEquals, []string{"database"})
+	meta = charm.Meta{Tags: []string{"database"}}
+	c.Assert(meta.AllCategories(), jc.DeepEquals, []string{"databases"})
+}
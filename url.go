@@ -34,6 +34,7 @@ type Location interface {
 //     cs:precise/wordpress-20
 //     cs:development/precise/wordpress-20
 //     cs:~joe/development/wordpress
+//     cs:trusty/wordpress-20?channel=edge
 //
 type URL struct {
 	Schema   string // "cs" or "local".
@@ -41,17 +42,22 @@ type URL struct {
 	Name     string // "wordpress".
 	Revision int    // -1 if unset, N otherwise.
 	Series   string // "precise" or "" if unset; "bundle" if it's a bundle.
+	Host     string // "store.example.com" or "" for the default store.
+	Channel  string // "edge" or "" if unset.
 }
 
 var (
 	ErrUnresolvedUrl error = fmt.Errorf("charm or bundle url series is not resolved")
 	validSeries            = regexp.MustCompile("^[a-z]+([a-z0-9]+)?$")
 	validName              = regexp.MustCompile("^[a-z][a-z0-9]*(-[a-z0-9]*[a-z][a-z0-9]*)*$")
+	validChannel           = regexp.MustCompile("^[a-z][a-z0-9]*(-[a-z0-9]+)*$")
 )
 
 // ValidateSchema returns an error if the schema is invalid.
+// Only the cs and local schemas, and those registered with
+// RegisterURLScheme, are valid.
 func ValidateSchema(schema string) error {
-	if schema != "cs" && schema != "local" {
+	if _, ok := lookupURLScheme(schema); !ok {
 		return errors.NotValidf("schema %q", schema)
 	}
 	return nil
@@ -84,6 +90,20 @@ func ValidateName(name string) error {
 	return nil
 }
 
+// IsValidChannel reports whether channel is a valid channel
+// in charm or bundle URLs.
+func IsValidChannel(channel string) bool {
+	return validChannel.MatchString(channel)
+}
+
+// ValidateChannel returns an error if the given channel is invalid.
+func ValidateChannel(channel string) error {
+	if IsValidChannel(channel) == false {
+		return errors.NotValidf("channel %q", channel)
+	}
+	return nil
+}
+
 // WithRevision returns a URL equivalent to url but with Revision set
 // to revision.
 func (url *URL) WithRevision(revision int) *URL {
@@ -92,6 +112,35 @@ func (url *URL) WithRevision(revision int) *URL {
 	return &urlCopy
 }
 
+// WithChannel returns a URL equivalent to url but with Channel set
+// to channel.
+func (url *URL) WithChannel(channel string) *URL {
+	urlCopy := *url
+	urlCopy.Channel = channel
+	return &urlCopy
+}
+
+// Base returns the base corresponding to the series of url.
+// It returns ErrUnresolvedUrl if the series is not set.
+func (url *URL) Base() (Base, error) {
+	if url.Series == "" {
+		return Base{}, ErrUnresolvedUrl
+	}
+	return BaseForSeries(url.Series)
+}
+
+// WithBase returns a URL equivalent to url but with Series set
+// to the series corresponding to base.
+func (url *URL) WithBase(base Base) (*URL, error) {
+	series, err := base.Series()
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	urlCopy := *url
+	urlCopy.Series = series
+	return &urlCopy, nil
+}
+
 // MustParseURL works like ParseURL, but panics in case of errors.
 func MustParseURL(url string) *URL {
 	u, err := ParseURL(url)
@@ -125,16 +174,38 @@ func MustParseURL(url string) *URL {
 //    https://jujucharms.com/u/user/channel/name/revision
 //    https://jujucharms.com/u/user/channel/name/series/revision
 //
+// A charm store URL may also name the store that holds the charm or
+// bundle, in which case the host is recorded in the Host field:
+//
+//    cs://store.example.com/~user/series/name-revision
+//
+// Any URL may name the channel that the charm or bundle should be
+// taken from as a query parameter, which is recorded in the Channel
+// field:
+//
+//    cs:series/name-revision?channel=edge
+//
 // A missing schema is assumed to be 'cs'.
+//
+// URLs with a schema registered with RegisterURLScheme are
+// parsed by the registered Parse function if there is one.
 func ParseURL(url string) (*URL, error) {
 	// Check if we're dealing with a v1 or v2 URL.
 	u, err := gourl.Parse(url)
 	if err != nil {
 		return nil, errors.Errorf("cannot parse charm or bundle URL: %q", url)
 	}
-	if u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+	if scheme, ok := lookupURLScheme(u.Scheme); ok && scheme.Parse != nil {
+		return parseSchemeURL(scheme, u.Scheme, url)
+	}
+	if u.Fragment != "" || u.User != nil {
 		return nil, errors.Errorf("charm or bundle URL %q has unrecognized parts", url)
 	}
+	channel, err := parseChannelQuery(u, url)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	u.RawQuery = ""
 	var curl *URL
 	switch {
 	case u.Opaque != "":
@@ -144,6 +215,9 @@ func ParseURL(url string) (*URL, error) {
 	case u.Scheme == "http" || u.Scheme == "https":
 		// Shortcut new-style URLs.
 		curl, err = parseV2URL(u)
+	case u.Host != "":
+		// Old-style URLs qualified with the store host.
+		curl, err = parseHostURL(u, url)
 	default:
 		// TODO: for now, fall through to parsing v1 references; this will be
 		// expanded to be more robust in the future.
@@ -155,9 +229,50 @@ func ParseURL(url string) (*URL, error) {
 	if curl.Schema == "" {
 		curl.Schema = "cs"
 	}
+	curl.Channel = channel
+	if err := validateURLScheme(curl); err != nil {
+		return nil, errors.Annotatef(err, "cannot parse URL %q", url)
+	}
 	return curl, nil
 }
 
+// parseSchemeURL parses a URL with the given registered scheme.
+func parseSchemeURL(scheme URLScheme, schema, url string) (*URL, error) {
+	curl, err := scheme.Parse(url)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	if curl == nil {
+		return nil, errors.Errorf("parser for schema %q returned no URL", schema)
+	}
+	if curl.Schema != schema {
+		return nil, errors.Errorf("parser for schema %q returned URL with schema %q", schema, curl.Schema)
+	}
+	if scheme.Validate != nil {
+		if err := scheme.Validate(curl); err != nil {
+			return nil, errors.Annotatef(err, "cannot parse URL %q", url)
+		}
+	}
+	return curl, nil
+}
+
+// parseChannelQuery returns the channel held in the query of
+// the given URL, or "" if there is no query.
+func parseChannelQuery(url *gourl.URL, originalURL string) (string, error) {
+	if url.RawQuery == "" {
+		return "", nil
+	}
+	query, err := gourl.ParseQuery(url.RawQuery)
+	if err != nil || len(query) != 1 || len(query["channel"]) != 1 {
+		return "", errors.Errorf("charm or bundle URL %q has unrecognized parts", originalURL)
+	}
+	channel := query.Get("channel")
+	if err := ValidateChannel(channel); err != nil {
+		return "", errors.Annotatef(err, "cannot parse URL %q", originalURL)
+	}
+	return channel, nil
+}
+
 func parseV1URL(url *gourl.URL, originalURL string) (*URL, error) {
 	var r URL
 	if url.Scheme != "" {
@@ -224,6 +339,22 @@ func parseV1URL(url *gourl.URL, originalURL string) (*URL, error) {
 	return &r, nil
 }
 
+func parseHostURL(url *gourl.URL, originalURL string) (*URL, error) {
+	if url.Scheme != "cs" {
+		return nil, errors.Errorf("charm or bundle URL with host must use the cs schema: %q", originalURL)
+	}
+	if !strings.HasPrefix(url.Path, "/") {
+		return nil, errors.Errorf("URL without charm or bundle name: %q", originalURL)
+	}
+	url.Path = url.Path[1:]
+	r, err := parseV1URL(url, originalURL)
+	if err != nil {
+		return nil, errors.Trace(err)
+	}
+	r.Host = url.Host
+	return r, nil
+}
+
 func parseV2URL(url *gourl.URL) (*URL, error) {
 	var r URL
 	r.Schema = "cs"
@@ -308,7 +439,16 @@ func InferURL(src, defaultSeries string) (*URL, error) {
 }
 
 func (u URL) String() string {
-	return fmt.Sprintf("%s:%s", u.Schema, u.Path())
+	var s string
+	if u.Host != "" {
+		s = fmt.Sprintf("%s://%s/%s", u.Schema, u.Host, u.Path())
+	} else {
+		s = fmt.Sprintf("%s:%s", u.Schema, u.Path())
+	}
+	if u.Channel != "" {
+		s += "?channel=" + u.Channel
+	}
+	return s
 }
 
 // GetBSON turns u into a bson.Getter so it can be saved directly
@@ -9,6 +9,8 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
 	gc "gopkg.in/check.v1"
 	"gopkg.in/mgo.v2/bson"
 	"gopkg.in/yaml.v2"
@@ -26,109 +28,146 @@ var urlTests = []struct {
 	url    *charm.URL
 }{{
 	s:   "cs:~user/series/name",
-	url: &charm.URL{"cs", "user", "name", -1, "series"},
+	url: &charm.URL{"cs", "user", "name", -1, "series", "", ""},
 }, {
 	s:   "cs:~user/series/name-0",
-	url: &charm.URL{"cs", "user", "name", 0, "series"},
+	url: &charm.URL{"cs", "user", "name", 0, "series", "", ""},
 }, {
 	s:   "cs:series/name",
-	url: &charm.URL{"cs", "", "name", -1, "series"},
+	url: &charm.URL{"cs", "", "name", -1, "series", "", ""},
 }, {
 	s:   "cs:series/name-42",
-	url: &charm.URL{"cs", "", "name", 42, "series"},
+	url: &charm.URL{"cs", "", "name", 42, "series", "", ""},
 }, {
 	s:   "local:series/name-1",
-	url: &charm.URL{"local", "", "name", 1, "series"},
+	url: &charm.URL{"local", "", "name", 1, "series", "", ""},
 }, {
 	s:   "local:series/name",
-	url: &charm.URL{"local", "", "name", -1, "series"},
+	url: &charm.URL{"local", "", "name", -1, "series", "", ""},
 }, {
 	s:   "local:series/n0-0n-n0",
-	url: &charm.URL{"local", "", "n0-0n-n0", -1, "series"},
+	url: &charm.URL{"local", "", "n0-0n-n0", -1, "series", "", ""},
 }, {
 	s:   "cs:~user/name",
-	url: &charm.URL{"cs", "user", "name", -1, ""},
+	url: &charm.URL{"cs", "user", "name", -1, "", "", ""},
 }, {
 	s:   "cs:name",
-	url: &charm.URL{"cs", "", "name", -1, ""},
+	url: &charm.URL{"cs", "", "name", -1, "", "", ""},
 }, {
 	s:   "local:name",
-	url: &charm.URL{"local", "", "name", -1, ""},
+	url: &charm.URL{"local", "", "name", -1, "", "", ""},
 }, {
 	s:     "http://jujucharms.com/u/user/name/series/1",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{"cs", "user", "name", 1, "series", "", ""},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "http://www.jujucharms.com/u/user/name/series/1",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{"cs", "user", "name", 1, "series", "", ""},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "https://www.jujucharms.com/u/user/name/series/1",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{"cs", "user", "name", 1, "series", "", ""},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/series/1",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{"cs", "user", "name", 1, "series", "", ""},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/series",
-	url:   &charm.URL{"cs", "user", "name", -1, "series"},
+	url:   &charm.URL{"cs", "user", "name", -1, "series", "", ""},
 	exact: "cs:~user/series/name",
 }, {
 	s:     "https://jujucharms.com/u/user/name/1",
-	url:   &charm.URL{"cs", "user", "name", 1, ""},
+	url:   &charm.URL{"cs", "user", "name", 1, "", "", ""},
 	exact: "cs:~user/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name",
-	url:   &charm.URL{"cs", "user", "name", -1, ""},
+	url:   &charm.URL{"cs", "user", "name", -1, "", "", ""},
 	exact: "cs:~user/name",
 }, {
 	s:     "https://jujucharms.com/name",
-	url:   &charm.URL{"cs", "", "name", -1, ""},
+	url:   &charm.URL{"cs", "", "name", -1, "", "", ""},
 	exact: "cs:name",
 }, {
 	s:     "https://jujucharms.com/name/series",
-	url:   &charm.URL{"cs", "", "name", -1, "series"},
+	url:   &charm.URL{"cs", "", "name", -1, "series", "", ""},
 	exact: "cs:series/name",
 }, {
 	s:     "https://jujucharms.com/name/1",
-	url:   &charm.URL{"cs", "", "name", 1, ""},
+	url:   &charm.URL{"cs", "", "name", 1, "", "", ""},
 	exact: "cs:name-1",
 }, {
 	s:     "https://jujucharms.com/name/series/1",
-	url:   &charm.URL{"cs", "", "name", 1, "series"},
+	url:   &charm.URL{"cs", "", "name", 1, "series", "", ""},
 	exact: "cs:series/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/series/1/",
-	url:   &charm.URL{"cs", "user", "name", 1, "series"},
+	url:   &charm.URL{"cs", "user", "name", 1, "series", "", ""},
 	exact: "cs:~user/series/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/series/",
-	url:   &charm.URL{"cs", "user", "name", -1, "series"},
+	url:   &charm.URL{"cs", "user", "name", -1, "series", "", ""},
 	exact: "cs:~user/series/name",
 }, {
 	s:     "https://jujucharms.com/u/user/name/1/",
-	url:   &charm.URL{"cs", "user", "name", 1, ""},
+	url:   &charm.URL{"cs", "user", "name", 1, "", "", ""},
 	exact: "cs:~user/name-1",
 }, {
 	s:     "https://jujucharms.com/u/user/name/",
-	url:   &charm.URL{"cs", "user", "name", -1, ""},
+	url:   &charm.URL{"cs", "user", "name", -1, "", "", ""},
 	exact: "cs:~user/name",
 }, {
 	s:     "https://jujucharms.com/name/",
-	url:   &charm.URL{"cs", "", "name", -1, ""},
+	url:   &charm.URL{"cs", "", "name", -1, "", "", ""},
 	exact: "cs:name",
 }, {
 	s:     "https://jujucharms.com/name/series/",
-	url:   &charm.URL{"cs", "", "name", -1, "series"},
+	url:   &charm.URL{"cs", "", "name", -1, "series", "", ""},
 	exact: "cs:series/name",
 }, {
 	s:     "https://jujucharms.com/name/1/",
-	url:   &charm.URL{"cs", "", "name", 1, ""},
+	url:   &charm.URL{"cs", "", "name", 1, "", "", ""},
 	exact: "cs:name-1",
+}, {
+	s:   "cs://store.example.com/~who/trusty/mysql-1",
+	url: &charm.URL{"cs", "who", "mysql", 1, "trusty", "store.example.com", ""},
+}, {
+	s:   "cs://store.example.com:8080/mysql",
+	url: &charm.URL{"cs", "", "mysql", -1, "", "store.example.com:8080", ""},
+}, {
+	s:   "cs://store.example.com/",
+	err: `cannot parse URL "cs://store.example.com/?": name "" not valid`,
+}, {
+	s:   "cs:trusty/mysql-3?channel=edge",
+	url: &charm.URL{"cs", "", "mysql", 3, "trusty", "", "edge"},
+}, {
+	s:   "cs://store.example.com/~who/mysql?channel=candidate",
+	url: &charm.URL{"cs", "who", "mysql", -1, "", "store.example.com", "candidate"},
+}, {
+	s:     "https://jujucharms.com/mysql/trusty/3?channel=beta",
+	url:   &charm.URL{"cs", "", "mysql", 3, "trusty", "", "beta"},
+	exact: "cs:trusty/mysql-3?channel=beta",
+}, {
+	s:   "cs:mysql?channel=Edge",
+	err: `cannot parse URL $URL: channel "Edge" not valid`,
+}, {
+	s:   "cs:mysql?channel=edge&channel=stable",
+	err: `charm or bundle URL $URL has unrecognized parts`,
+}, {
+	s:   "cs:mysql?channel=edge&series=trusty",
+	err: `charm or bundle URL $URL has unrecognized parts`,
+}, {
+	s:   "cs://store.example.com",
+	err: `URL without charm or bundle name: $URL`,
+}, {
+	s:   "local://store.example.com/trusty/mysql",
+	err: `charm or bundle URL with host must use the cs schema: $URL`,
+}, {
+	s:   "cs://who@store.example.com/mysql",
+	err: `charm or bundle URL $URL has unrecognized parts`,
 }, {
 	s:     "https://jujucharms.com/name/series/1/",
-	url:   &charm.URL{"cs", "", "name", 1, "series"},
+	url:   &charm.URL{"cs", "", "name", 1, "series", "", ""},
 	exact: "cs:series/name-1",
 }, {
 	s:   "https://jujucharms.com/",
@@ -202,31 +241,31 @@ var urlTests = []struct {
 }, {
 	s:     "precise/wordpress",
 	exact: "cs:precise/wordpress",
-	url:   &charm.URL{"cs", "", "wordpress", -1, "precise"},
+	url:   &charm.URL{"cs", "", "wordpress", -1, "precise", "", ""},
 }, {
 	s:     "foo",
 	exact: "cs:foo",
-	url:   &charm.URL{"cs", "", "foo", -1, ""},
+	url:   &charm.URL{"cs", "", "foo", -1, "", "", ""},
 }, {
 	s:     "foo-1",
 	exact: "cs:foo-1",
-	url:   &charm.URL{"cs", "", "foo", 1, ""},
+	url:   &charm.URL{"cs", "", "foo", 1, "", "", ""},
 }, {
 	s:     "n0-n0-n0",
 	exact: "cs:n0-n0-n0",
-	url:   &charm.URL{"cs", "", "n0-n0-n0", -1, ""},
+	url:   &charm.URL{"cs", "", "n0-n0-n0", -1, "", "", ""},
 }, {
 	s:     "cs:foo",
 	exact: "cs:foo",
-	url:   &charm.URL{"cs", "", "foo", -1, ""},
+	url:   &charm.URL{"cs", "", "foo", -1, "", "", ""},
 }, {
 	s:     "local:foo",
 	exact: "local:foo",
-	url:   &charm.URL{"local", "", "foo", -1, ""},
+	url:   &charm.URL{"local", "", "foo", -1, "", "", ""},
 }, {
 	s:     "series/foo",
 	exact: "cs:series/foo",
-	url:   &charm.URL{"cs", "", "foo", -1, "series"},
+	url:   &charm.URL{"cs", "", "foo", -1, "series", "", ""},
 }, {
 	s:   "series/foo/bar",
 	err: `charm or bundle URL has invalid form: "series/foo/bar"`,
@@ -375,7 +414,7 @@ func (s *URLSuite) TestValidCheckers(c *gc.C) {
 
 func (s *URLSuite) TestMustParseURL(c *gc.C) {
 	url := charm.MustParseURL("cs:series/name")
-	c.Assert(url, gc.DeepEquals, &charm.URL{"cs", "", "name", -1, "series"})
+	c.Assert(url, gc.DeepEquals, &charm.URL{"cs", "", "name", -1, "series", "", ""})
 	f := func() { charm.MustParseURL("local:@@/name") }
 	c.Assert(f, gc.PanicMatches, "cannot parse URL \"local:@@/name\": series name \"@@\" not valid")
 	f = func() { charm.MustParseURL("cs:~user") }
@@ -387,14 +426,38 @@ func (s *URLSuite) TestMustParseURL(c *gc.C) {
 func (s *URLSuite) TestWithRevision(c *gc.C) {
 	url := charm.MustParseURL("cs:series/name")
 	other := url.WithRevision(1)
-	c.Assert(url, gc.DeepEquals, &charm.URL{"cs", "", "name", -1, "series"})
-	c.Assert(other, gc.DeepEquals, &charm.URL{"cs", "", "name", 1, "series"})
+	c.Assert(url, gc.DeepEquals, &charm.URL{"cs", "", "name", -1, "series", "", ""})
+	c.Assert(other, gc.DeepEquals, &charm.URL{"cs", "", "name", 1, "series", "", ""})
 
 	// Should always copy. The opposite behavior is error prone.
 	c.Assert(other.WithRevision(1), gc.Not(gc.Equals), other)
 	c.Assert(other.WithRevision(1), gc.DeepEquals, other)
 }
 
+func (s *URLSuite) TestBase(c *gc.C) {
+	base, err := charm.MustParseURL("cs:focal/name").Base()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(base, gc.Equals, charm.Base{Name: "ubuntu", Channel: "20.04"})
+
+	_, err = charm.MustParseURL("cs:someseries/name").Base()
+	c.Assert(err, gc.ErrorMatches, `base for series "someseries" not found`)
+	c.Assert(errors.IsNotFound(err), jc.IsTrue)
+
+	_, err = charm.MustParseURL("cs:name").Base()
+	c.Assert(err, gc.Equals, charm.ErrUnresolvedUrl)
+}
+
+func (s *URLSuite) TestWithBase(c *gc.C) {
+	url := charm.MustParseURL("cs:name-3")
+	other, err := url.WithBase(charm.MustParseBase("ubuntu/18.04"))
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(url, gc.DeepEquals, &charm.URL{"cs", "", "name", 3, "", "", ""})
+	c.Assert(other, gc.DeepEquals, &charm.URL{"cs", "", "name", 3, "bionic", "", ""})
+
+	_, err = url.WithBase(charm.MustParseBase("ubuntu/99.04"))
+	c.Assert(err, gc.ErrorMatches, `series for base "ubuntu/99.04" not found`)
+}
+
 var codecs = []struct {
 	Name      string
 	Marshal   func(interface{}) ([]byte, error)
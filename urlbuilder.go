@@ -0,0 +1,149 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	gourl "net/url"
+	"strings"
+	"unicode"
+
+	"github.com/juju/errors"
+	"gopkg.in/juju/names.v2"
+)
+
+// URLBuilder builds a charm or bundle URL from its parts.
+// The parts are only checked when Build is called, so
+// the With methods may be chained:
+//
+//	curl, err := charm.NewURLBuilder().
+//	    WithUser("who").
+//	    WithSeries("trusty").
+//	    WithName("mysql").
+//	    WithRevision(3).
+//	    Build()
+type URLBuilder struct {
+	url URL
+}
+
+// NewURLBuilder returns a builder for a URL with the "cs" schema
+// and no revision. The name must be set before calling Build.
+func NewURLBuilder() *URLBuilder {
+	return &URLBuilder{
+		url: URL{
+			Schema:   "cs",
+			Revision: -1,
+		},
+	}
+}
+
+// WithSchema sets the schema of the URL.
+func (b *URLBuilder) WithSchema(schema string) *URLBuilder {
+	b.url.Schema = schema
+	return b
+}
+
+// WithUser sets the user owning the charm or bundle.
+func (b *URLBuilder) WithUser(user string) *URLBuilder {
+	b.url.User = user
+	return b
+}
+
+// WithName sets the name of the charm or bundle.
+func (b *URLBuilder) WithName(name string) *URLBuilder {
+	b.url.Name = name
+	return b
+}
+
+// WithRevision sets the revision of the URL. A revision
+// of -1 means that the revision is unset.
+func (b *URLBuilder) WithRevision(revision int) *URLBuilder {
+	b.url.Revision = revision
+	return b
+}
+
+// WithSeries sets the series of the URL.
+func (b *URLBuilder) WithSeries(series string) *URLBuilder {
+	b.url.Series = series
+	return b
+}
+
+// WithHost sets the host of the charm store holding
+// the charm or bundle.
+func (b *URLBuilder) WithHost(host string) *URLBuilder {
+	b.url.Host = host
+	return b
+}
+
+// WithChannel sets the channel of the URL.
+func (b *URLBuilder) WithChannel(channel string) *URLBuilder {
+	b.url.Channel = channel
+	return b
+}
+
+// Build checks the parts set on the builder and returns
+// the resulting URL. The builder may be used again
+// afterwards without affecting the returned URL.
+func (b *URLBuilder) Build() (*URL, error) {
+	if err := b.validate(); err != nil {
+		return nil, errors.Annotate(err, "cannot build charm or bundle URL")
+	}
+	url := b.url
+	return &url, nil
+}
+
+func (b *URLBuilder) validate() error {
+	u := &b.url
+	if err := ValidateSchema(u.Schema); err != nil {
+		return errors.Trace(err)
+	}
+	if u.User != "" {
+		if u.Schema == "local" {
+			return errors.Errorf("local URL with user name %q", u.User)
+		}
+		if !names.IsValidUser(u.User) {
+			return errors.NotValidf("user name %q", u.User)
+		}
+	}
+	if u.Name == "" {
+		return errors.New("name not specified")
+	}
+	if err := ValidateName(u.Name); err != nil {
+		return errors.Trace(err)
+	}
+	if u.Revision < -1 {
+		return errors.NotValidf("revision %d", u.Revision)
+	}
+	if u.Series != "" {
+		if err := ValidateSeries(u.Series); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if u.Host != "" {
+		if u.Schema != "cs" {
+			return errors.Errorf("URL with host %q must use the cs schema", u.Host)
+		}
+		if err := validateHost(u.Host); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	if u.Channel != "" {
+		if err := ValidateChannel(u.Channel); err != nil {
+			return errors.Trace(err)
+		}
+	}
+	return errors.Trace(validateURLScheme(u))
+}
+
+// validateHost returns an error if host cannot be used
+// as the host of a charm store URL.
+func validateHost(host string) error {
+	if strings.Contains(host, "/") || strings.IndexFunc(host, unicode.IsSpace) != -1 {
+		return errors.NotValidf("host %q", host)
+	}
+	u, err := gourl.Parse("cs://" + host)
+	if err != nil || u.Host != host || u.User != nil || u.Path != "" {
+		return errors.NotValidf("host %q", host)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type URLBuilderSuite struct{}
+
+var _ = gc.Suite(&URLBuilderSuite{})
+
+func (s *URLBuilderSuite) TestBuild(c *gc.C) {
+	curl, err := charm.NewURLBuilder().
+		WithSchema("cs").
+		WithUser("who").
+		WithSeries("trusty").
+		WithName("mysql").
+		WithRevision(3).
+		Build()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(curl, jc.DeepEquals, charm.MustParseURL("cs:~who/trusty/mysql-3"))
+}
+
+func (s *URLBuilderSuite) TestBuildDefaults(c *gc.C) {
+	curl, err := charm.NewURLBuilder().WithName("mysql").Build()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(curl, jc.DeepEquals, charm.MustParseURL("cs:mysql"))
+}
+
+func (s *URLBuilderSuite) TestBuildHostAndChannel(c *gc.C) {
+	curl, err := charm.NewURLBuilder().
+		WithHost("store.example.com").
+		WithName("mysql").
+		WithChannel("edge").
+		Build()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(curl.String(), gc.Equals, "cs://store.example.com/mysql?channel=edge")
+}
+
+func (s *URLBuilderSuite) TestBuilderReuse(c *gc.C) {
+	b := charm.NewURLBuilder().WithName("mysql")
+	curl1, err := b.Build()
+	c.Assert(err, jc.ErrorIsNil)
+	curl2, err := b.WithRevision(5).Build()
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(curl1.String(), gc.Equals, "cs:mysql")
+	c.Assert(curl2.String(), gc.Equals, "cs:mysql-5")
+}
+
+var urlBuilderErrorTests = []struct {
+	about string
+	build func(b *charm.URLBuilder) *charm.URLBuilder
+	err   string
+}{{
+	about: "no name",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithSeries("trusty")
+	},
+	err: `cannot build charm or bundle URL: name not specified`,
+}, {
+	about: "invalid name",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithName("My SQL")
+	},
+	err: `cannot build charm or bundle URL: name "My SQL" not valid`,
+}, {
+	about: "invalid schema",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithSchema("http").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: schema "http" not valid`,
+}, {
+	about: "invalid user",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithUser("$who").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: user name "\$who" not valid`,
+}, {
+	about: "local with user",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithSchema("local").WithUser("who").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: local URL with user name "who"`,
+}, {
+	about: "invalid series",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithSeries("Trusty").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: series name "Trusty" not valid`,
+}, {
+	about: "invalid revision",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithName("mysql").WithRevision(-2)
+	},
+	err: `cannot build charm or bundle URL: revision -2 not valid`,
+}, {
+	about: "local with host",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithSchema("local").WithHost("store.example.com").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: URL with host "store.example.com" must use the cs schema`,
+}, {
+	about: "host with path and space",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithHost("a/b c").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: host "a/b c" not valid`,
+}, {
+	about: "host with space",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithHost("store example").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: host "store example" not valid`,
+}, {
+	about: "host with user",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithHost("who@store.example.com").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: host "who@store.example.com" not valid`,
+}, {
+	about: "host with query",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithHost("store.example.com?x=y").WithName("mysql")
+	},
+	err: `cannot build charm or bundle URL: host "store.example.com\?x=y" not valid`,
+}, {
+	about: "invalid channel",
+	build: func(b *charm.URLBuilder) *charm.URLBuilder {
+		return b.WithName("mysql").WithChannel("Edge")
+	},
+	err: `cannot build charm or bundle URL: channel "Edge" not valid`,
+}}
+
+func (s *URLBuilderSuite) TestBuildErrors(c *gc.C) {
+	for i, test := range urlBuilderErrorTests {
+		c.Logf("test %d: %s", i, test.about)
+		curl, err := test.build(charm.NewURLBuilder()).Build()
+		c.Assert(err, gc.ErrorMatches, test.err)
+		c.Assert(curl, gc.IsNil)
+	}
+}
+
+func (s *URLBuilderSuite) TestBuildRoundTrip(c *gc.C) {
+	for i, b := range []*charm.URLBuilder{
+		charm.NewURLBuilder().WithName("mysql"),
+		charm.NewURLBuilder().WithUser("who").WithSeries("trusty").WithName("mysql").WithRevision(3),
+		charm.NewURLBuilder().WithSchema("local").WithSeries("trusty").WithName("mysql"),
+		charm.NewURLBuilder().WithHost("store.example.com").WithName("mysql").WithChannel("edge"),
+		charm.NewURLBuilder().WithHost("store.example.com:8080").WithUser("who").WithName("mysql"),
+		charm.NewURLBuilder().WithHost("[::1]:8080").WithSeries("bundle").WithName("mysql-cluster"),
+	} {
+		curl, err := b.Build()
+		c.Assert(err, jc.ErrorIsNil)
+		c.Logf("test %d: %s", i, curl)
+		parsed, err := charm.ParseURL(curl.String())
+		c.Assert(err, jc.ErrorIsNil)
+		c.Assert(parsed, jc.DeepEquals, curl)
+	}
+}
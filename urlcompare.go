@@ -0,0 +1,69 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import "strings"
+
+// Canonical returns a copy of url in canonical form, so that
+// URLs that refer to the same charm or bundle compare equal.
+// A missing schema is set to "cs", an unset or negative revision
+// is set to -1 and the default "stable" channel is removed.
+func (url *URL) Canonical() *URL {
+	urlCopy := *url
+	if urlCopy.Schema == "" {
+		urlCopy.Schema = "cs"
+	}
+	if urlCopy.Revision < 0 {
+		urlCopy.Revision = -1
+	}
+	if urlCopy.Channel == "stable" {
+		urlCopy.Channel = ""
+	}
+	return &urlCopy
+}
+
+// EqualsIgnoringRevision reports whether url and other refer
+// to the same charm or bundle, regardless of their revisions.
+func (url *URL) EqualsIgnoringRevision(other *URL) bool {
+	return *url.Canonical().WithRevision(-1) == *other.Canonical().WithRevision(-1)
+}
+
+// Compare returns an integer comparing url with other. The result
+// is 0 if both refer to the same charm or bundle, -1 if url sorts
+// before other and +1 otherwise. URLs are ordered by name, then
+// series, then revision; URLs that differ only in other parts are
+// ordered by schema, user, host and channel.
+func (url *URL) Compare(other *URL) int {
+	u0, u1 := url.Canonical(), other.Canonical()
+	if c := strings.Compare(u0.Name, u1.Name); c != 0 {
+		return c
+	}
+	if c := strings.Compare(u0.Series, u1.Series); c != 0 {
+		return c
+	}
+	if u0.Revision != u1.Revision {
+		if u0.Revision < u1.Revision {
+			return -1
+		}
+		return 1
+	}
+	if c := strings.Compare(u0.Schema, u1.Schema); c != 0 {
+		return c
+	}
+	if c := strings.Compare(u0.User, u1.User); c != 0 {
+		return c
+	}
+	if c := strings.Compare(u0.Host, u1.Host); c != 0 {
+		return c
+	}
+	return strings.Compare(u0.Channel, u1.Channel)
+}
+
+// URLSlice implements sort.Interface to sort URLs
+// in the order defined by URL.Compare.
+type URLSlice []*URL
+
+func (s URLSlice) Len() int           { return len(s) }
+func (s URLSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s URLSlice) Less(i, j int) bool { return s[i].Compare(s[j]) < 0 }
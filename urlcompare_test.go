@@ -0,0 +1,106 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"sort"
+
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type URLCompareSuite struct{}
+
+var _ = gc.Suite(&URLCompareSuite{})
+
+func (s *URLCompareSuite) TestCanonical(c *gc.C) {
+	url := &charm.URL{
+		Name:     "mysql",
+		Revision: -5,
+		Series:   "trusty",
+		Channel:  "stable",
+	}
+	c.Assert(url.Canonical(), jc.DeepEquals, charm.MustParseURL("cs:trusty/mysql"))
+	// The original is unchanged.
+	c.Assert(url.Schema, gc.Equals, "")
+
+	url = charm.MustParseURL("local:trusty/mysql-3?channel=edge")
+	c.Assert(url.Canonical(), jc.DeepEquals, url)
+	c.Assert(url.Canonical(), gc.Not(gc.Equals), url)
+}
+
+var equalsIgnoringRevisionTests = []struct {
+	url0, url1 string
+	expect     bool
+}{
+	{"cs:trusty/mysql-3", "cs:trusty/mysql-3", true},
+	{"cs:trusty/mysql-3", "cs:trusty/mysql-4", true},
+	{"cs:trusty/mysql", "cs:trusty/mysql-4", true},
+	{"cs:trusty/mysql?channel=stable", "cs:trusty/mysql-4", true},
+	{"cs:trusty/mysql", "cs:xenial/mysql", false},
+	{"cs:trusty/mysql", "local:trusty/mysql", false},
+	{"cs:~who/trusty/mysql", "cs:trusty/mysql", false},
+	{"cs:trusty/mysql", "cs://store.example.com/trusty/mysql", false},
+	{"cs:trusty/mysql?channel=edge", "cs:trusty/mysql", false},
+}
+
+func (s *URLCompareSuite) TestEqualsIgnoringRevision(c *gc.C) {
+	for i, test := range equalsIgnoringRevisionTests {
+		c.Logf("test %d: %s %s", i, test.url0, test.url1)
+		url0, url1 := charm.MustParseURL(test.url0), charm.MustParseURL(test.url1)
+		c.Assert(url0.EqualsIgnoringRevision(url1), gc.Equals, test.expect)
+		c.Assert(url1.EqualsIgnoringRevision(url0), gc.Equals, test.expect)
+	}
+}
+
+var compareTests = []struct {
+	url0, url1 string
+	expect     int
+}{
+	{"cs:trusty/mysql-3", "cs:trusty/mysql-3", 0},
+	{"cs:trusty/mysql-3?channel=stable", "cs:trusty/mysql-3", 0},
+	{"cs:trusty/mysql-3", "cs:trusty/wordpress-1", -1},
+	{"cs:xenial/mysql-1", "cs:trusty/mysql-3", 1},
+	{"cs:trusty/mysql", "cs:trusty/mysql-0", -1},
+	{"cs:trusty/mysql-10", "cs:trusty/mysql-9", 1},
+	{"cs:trusty/mysql-3", "local:trusty/mysql-3", -1},
+	{"cs:~who/trusty/mysql-3", "cs:trusty/mysql-3", 1},
+	{"cs://store.example.com/trusty/mysql-3", "cs:trusty/mysql-3", 1},
+	{"cs:trusty/mysql-3?channel=edge", "cs:trusty/mysql-3", 1},
+}
+
+func (s *URLCompareSuite) TestCompare(c *gc.C) {
+	for i, test := range compareTests {
+		c.Logf("test %d: %s %s", i, test.url0, test.url1)
+		url0, url1 := charm.MustParseURL(test.url0), charm.MustParseURL(test.url1)
+		c.Assert(url0.Compare(url1), gc.Equals, test.expect)
+		c.Assert(url1.Compare(url0), gc.Equals, -test.expect)
+	}
+}
+
+func (s *URLCompareSuite) TestSort(c *gc.C) {
+	urls := []*charm.URL{
+		charm.MustParseURL("cs:xenial/mysql-1"),
+		charm.MustParseURL("cs:trusty/wordpress"),
+		charm.MustParseURL("cs:trusty/mysql-10"),
+		charm.MustParseURL("cs:bundle/mysql-cluster"),
+		charm.MustParseURL("cs:trusty/mysql-9"),
+		charm.MustParseURL("cs:~who/trusty/mysql-9"),
+	}
+	sort.Sort(charm.URLSlice(urls))
+	var got []string
+	for _, url := range urls {
+		got = append(got, url.String())
+	}
+	c.Assert(got, jc.DeepEquals, []string{
+		"cs:trusty/mysql-9",
+		"cs:~who/trusty/mysql-9",
+		"cs:trusty/mysql-10",
+		"cs:xenial/mysql-1",
+		"cs:bundle/mysql-cluster",
+		"cs:trusty/wordpress",
+	})
+}
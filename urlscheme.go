@@ -0,0 +1,91 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"regexp"
+	"sync"
+
+	"github.com/juju/errors"
+)
+
+// URLScheme describes how charm or bundle URLs with
+// a given schema are parsed and validated.
+type URLScheme struct {
+	// Parse, if not nil, is used by ParseURL to parse URLs
+	// with the schema. It is passed the whole URL string.
+	// If Parse is nil, the URL is parsed in the same way as a
+	// cs URL, for example "ch:~user/series/name-revision".
+	Parse func(url string) (*URL, error)
+
+	// Validate, if not nil, is called to check each URL with
+	// the schema after it has been parsed by ParseURL or
+	// built by a URLBuilder.
+	Validate func(url *URL) error
+}
+
+var (
+	validSchema = regexp.MustCompile("^[a-z][a-z0-9+.-]*$")
+
+	urlSchemesMutex sync.RWMutex
+	urlSchemes      = map[string]URLScheme{
+		"cs":    {},
+		"local": {},
+	}
+)
+
+// RegisterURLScheme registers a URL schema so that ParseURL
+// and ValidateSchema accept it. The cs and local schemas are
+// always registered. It returns an error if the schema is
+// already registered, or if it is http or https, which ParseURL
+// always treats as charm store web addresses.
+func RegisterURLScheme(schema string, scheme URLScheme) error {
+	if !validSchema.MatchString(schema) {
+		return errors.NotValidf("schema %q", schema)
+	}
+	if schema == "http" || schema == "https" {
+		return errors.Errorf("schema %q is reserved for charm store web URLs", schema)
+	}
+	urlSchemesMutex.Lock()
+	defer urlSchemesMutex.Unlock()
+	if _, ok := urlSchemes[schema]; ok {
+		return errors.AlreadyExistsf("schema %q", schema)
+	}
+	urlSchemes[schema] = scheme
+	return nil
+}
+
+// UnregisterURLScheme removes a schema registered with
+// RegisterURLScheme. The cs and local schemas cannot
+// be removed.
+func UnregisterURLScheme(schema string) {
+	if schema == "cs" || schema == "local" {
+		return
+	}
+	urlSchemesMutex.Lock()
+	defer urlSchemesMutex.Unlock()
+	delete(urlSchemes, schema)
+}
+
+// lookupURLScheme returns the scheme registered for the
+// given schema and reports whether there is one.
+func lookupURLScheme(schema string) (URLScheme, bool) {
+	urlSchemesMutex.RLock()
+	defer urlSchemesMutex.RUnlock()
+	scheme, ok := urlSchemes[schema]
+	return scheme, ok
+}
+
+// validateURLScheme calls the Validate function registered
+// for the schema of the given URL, if any.
+func validateURLScheme(url *URL) error {
+	scheme, ok := lookupURLScheme(url.Schema)
+	if !ok {
+		return errors.NotValidf("schema %q", url.Schema)
+	}
+	if scheme.Validate == nil {
+		return nil
+	}
+	return scheme.Validate(url)
+}
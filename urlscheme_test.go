@@ -0,0 +1,141 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm_test
+
+import (
+	"strings"
+
+	"github.com/juju/errors"
+	jc "github.com/juju/testing/checkers"
+	gc "gopkg.in/check.v1"
+
+	"gopkg.in/juju/charm.v6"
+)
+
+type URLSchemeSuite struct{}
+
+var _ = gc.Suite(&URLSchemeSuite{})
+
+func (s *URLSchemeSuite) TestRegisterDefaultParser(c *gc.C) {
+	err := charm.RegisterURLScheme("ch", charm.URLScheme{
+		Validate: func(curl *charm.URL) error {
+			if curl.Series == "" {
+				return errors.New("series not specified")
+			}
+			return nil
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	defer charm.UnregisterURLScheme("ch")
+
+	c.Assert(charm.ValidateSchema("ch"), jc.ErrorIsNil)
+	curl, err := charm.ParseURL("ch:~who/trusty/mysql-3")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(curl, jc.DeepEquals, &charm.URL{
+		Schema:   "ch",
+		User:     "who",
+		Name:     "mysql",
+		Revision: 3,
+		Series:   "trusty",
+	})
+	c.Assert(curl.String(), gc.Equals, "ch:~who/trusty/mysql-3")
+
+	_, err = charm.ParseURL("ch:mysql")
+	c.Assert(err, gc.ErrorMatches, `cannot parse URL "ch:mysql": series not specified`)
+
+	_, err = charm.NewURLBuilder().WithSchema("ch").WithName("mysql").Build()
+	c.Assert(err, gc.ErrorMatches, `cannot build charm or bundle URL: series not specified`)
+
+	charm.UnregisterURLScheme("ch")
+	_, err = charm.ParseURL("ch:trusty/mysql")
+	c.Assert(err, gc.ErrorMatches, `cannot parse URL "ch:trusty/mysql": schema "ch" not valid`)
+}
+
+func (s *URLSchemeSuite) TestRegisterParser(c *gc.C) {
+	err := charm.RegisterURLScheme("oci", charm.URLScheme{
+		Parse: func(url string) (*charm.URL, error) {
+			name := strings.TrimPrefix(url, "oci://registry/")
+			if name == url {
+				return nil, errors.Errorf("unexpected oci URL %q", url)
+			}
+			return &charm.URL{
+				Schema:   "oci",
+				Name:     name,
+				Revision: -1,
+			}, nil
+		},
+		Validate: func(curl *charm.URL) error {
+			return charm.ValidateName(curl.Name)
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	defer charm.UnregisterURLScheme("oci")
+
+	curl, err := charm.ParseURL("oci://registry/mysql")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(curl, jc.DeepEquals, &charm.URL{
+		Schema:   "oci",
+		Name:     "mysql",
+		Revision: -1,
+	})
+
+	_, err = charm.ParseURL("oci://elsewhere/mysql")
+	c.Assert(err, gc.ErrorMatches, `unexpected oci URL "oci://elsewhere/mysql"`)
+
+	_, err = charm.ParseURL("oci://registry/My_SQL")
+	c.Assert(err, gc.ErrorMatches, `cannot parse URL "oci://registry/My_SQL": name "My_SQL" not valid`)
+}
+
+func (s *URLSchemeSuite) TestParserMustReturnSchema(c *gc.C) {
+	err := charm.RegisterURLScheme("other", charm.URLScheme{
+		Parse: func(url string) (*charm.URL, error) {
+			return &charm.URL{Schema: "cs", Name: "mysql", Revision: -1}, nil
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	defer charm.UnregisterURLScheme("other")
+
+	_, err = charm.ParseURL("other:mysql")
+	c.Assert(err, gc.ErrorMatches, `parser for schema "other" returned URL with schema "cs"`)
+}
+
+func (s *URLSchemeSuite) TestParserMustReturnURL(c *gc.C) {
+	err := charm.RegisterURLScheme("other", charm.URLScheme{
+		Parse: func(url string) (*charm.URL, error) {
+			return nil, nil
+		},
+	})
+	c.Assert(err, jc.ErrorIsNil)
+	defer charm.UnregisterURLScheme("other")
+
+	_, err = charm.ParseURL("other:mysql")
+	c.Assert(err, gc.ErrorMatches, `parser for schema "other" returned no URL`)
+}
+
+func (s *URLSchemeSuite) TestRegisterErrors(c *gc.C) {
+	err := charm.RegisterURLScheme("cs", charm.URLScheme{})
+	c.Assert(err, gc.ErrorMatches, `schema "cs" already exists`)
+	c.Assert(err, jc.Satisfies, errors.IsAlreadyExists)
+
+	err = charm.RegisterURLScheme("Bad Schema", charm.URLScheme{})
+	c.Assert(err, gc.ErrorMatches, `schema "Bad Schema" not valid`)
+
+	// The web URL schemas that ParseURL handles itself
+	// cannot be taken over.
+	for _, schema := range []string{"http", "https"} {
+		err = charm.RegisterURLScheme(schema, charm.URLScheme{
+			Parse: func(string) (*charm.URL, error) {
+				return nil, errors.New("unexpected call")
+			},
+		})
+		c.Assert(err, gc.ErrorMatches, `schema "`+schema+`" is reserved for charm store web URLs`)
+	}
+	curl, err := charm.ParseURL("https://jujucharms.com/mysql/trusty/3")
+	c.Assert(err, jc.ErrorIsNil)
+	c.Assert(curl.String(), gc.Equals, "cs:trusty/mysql-3")
+
+	// The built-in schemas cannot be removed.
+	charm.UnregisterURLScheme("local")
+	c.Assert(charm.ValidateSchema("local"), jc.ErrorIsNil)
+}
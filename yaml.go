@@ -0,0 +1,251 @@
+// Copyright 2018 Canonical Ltd.
+// Licensed under the LGPLv3, see LICENCE file for details.
+
+package charm
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/juju/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// DuplicateKeysError is returned by the strict readers when a YAML
+// document defines the same mapping key more than once.
+type DuplicateKeysError struct {
+	// File holds the kind of file being read, for example "metadata".
+	File string
+
+	// Duplicates holds a description of each repeated key,
+	// including the line where it was redefined.
+	Duplicates []string
+}
+
+func (err *DuplicateKeysError) Error() string {
+	return err.File + ": duplicate keys: " + strings.Join(err.Duplicates, "; ")
+}
+
+// IsDuplicateKeysError reports whether err is a *DuplicateKeysError.
+func IsDuplicateKeysError(err error) bool {
+	_, ok := err.(*DuplicateKeysError)
+	return ok
+}
+
+// utf8BOM holds the byte order mark that some editors
+// write at the start of UTF-8 files.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// EncodingError is returned by the YAML readers when their
+// input is not encoded as UTF-8.
+type EncodingError struct {
+	// File holds the name of the file being read,
+	// for example "metadata.yaml".
+	File string
+
+	// Offset holds the byte offset of the first invalid
+	// UTF-8 sequence, counted from the start of the file,
+	// including any byte order mark.
+	Offset int
+}
+
+func (err *EncodingError) Error() string {
+	return fmt.Sprintf("%s: invalid UTF-8 at byte offset %d (files must be UTF-8 encoded)", err.File, err.Offset)
+}
+
+// IsEncodingError reports whether err is an *EncodingError.
+func IsEncodingError(err error) bool {
+	_, ok := err.(*EncodingError)
+	return ok
+}
+
+// normalizeYAML returns data without any leading UTF-8 byte order
+// mark, or an *EncodingError if the data is not valid UTF-8. Other
+// encodings, including UTF-16, are rejected rather than being left
+// to produce confusing errors from the YAML parser. The kind names
+// the file being read, for example "metadata" for metadata.yaml.
+func normalizeYAML(kind string, data []byte) ([]byte, error) {
+	offset := 0
+	if bytes.HasPrefix(data, utf8BOM) {
+		offset = len(utf8BOM)
+	}
+	if utf8.Valid(data[offset:]) {
+		return data[offset:], nil
+	}
+	for offset < len(data) {
+		r, size := utf8.DecodeRune(data[offset:])
+		if r == utf8.RuneError && size == 1 {
+			break
+		}
+		offset += size
+	}
+	return nil, &EncodingError{
+		File:   kind + ".yaml",
+		Offset: offset,
+	}
+}
+
+// checkDuplicateKeys looks for mapping keys that are defined more than
+// once in the given YAML data. The normal decoder silently keeps the
+// last value, which is rarely what the author intended. If strict is
+// true the duplicates are returned as a *DuplicateKeysError, otherwise
+// they are logged as warnings and nil is returned.
+//
+// Malformed YAML is not reported here; that is left to the caller's
+// own unmarshaling, which produces the more familiar errors.
+func checkDuplicateKeys(file string, data []byte, strict bool) error {
+	var v interface{}
+	err := yaml.UnmarshalStrict(data, &v)
+	if err == nil {
+		return nil
+	}
+	terr, ok := err.(*yaml.TypeError)
+	if !ok {
+		return nil
+	}
+	var dups []string
+	for _, msg := range terr.Errors {
+		if strings.HasSuffix(msg, "already set in map") {
+			dups = append(dups, msg)
+		}
+	}
+	if len(dups) == 0 {
+		return nil
+	}
+	if strict {
+		return &DuplicateKeysError{
+			File:       file,
+			Duplicates: dups,
+		}
+	}
+	for _, dup := range dups {
+		logger.Warningf("%s: duplicate key found: %s", file, dup)
+	}
+	return nil
+}
+
+// errYAMLTooLarge is returned by countYAMLNodes when a document
+// holds more nodes than allowed.
+var errYAMLTooLarge = errors.New("too many YAML nodes")
+
+// recursiveAliasError is returned by countYAMLNodes when a document
+// holds an alias inside the value of its own anchor, so that it
+// cannot be expanded.
+type recursiveAliasError struct {
+	err error
+}
+
+func (err *recursiveAliasError) Error() string {
+	return "recursive alias: " + strings.TrimPrefix(err.err.Error(), "yaml: ")
+}
+
+var (
+	// yamlNodeCounterMutex guards activeYAMLNodeCounter.
+	yamlNodeCounterMutex sync.Mutex
+
+	// activeYAMLNodeCounter holds the counter used by
+	// yamlNodeVisitor. The visitors are created by the YAML
+	// decoder, so they cannot be given the counter directly.
+	activeYAMLNodeCounter *yamlNodeCounter
+)
+
+// countYAMLNodes returns the number of nodes in the given YAML
+// document as they would be after expanding anchors and aliases,
+// giving up with errYAMLTooLarge as soon as the count exceeds max,
+// unless max is zero. A small document that expands to a huge one
+// (the "billion laughs" attack) is rejected without first being
+// expanded in memory.
+//
+// The nodes are visited while the decoder is still expanding them,
+// so its check for an alias nested in the value of its own anchor
+// applies, and such an alias results in a *recursiveAliasError
+// rather than being followed for ever.
+func countYAMLNodes(data []byte, max int) (int, error) {
+	yamlNodeCounterMutex.Lock()
+	defer yamlNodeCounterMutex.Unlock()
+	counter := &yamlNodeCounter{max: max}
+	activeYAMLNodeCounter = counter
+	defer func() {
+		activeYAMLNodeCounter = nil
+	}()
+	var root yamlNodeVisitor
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return 0, counter.checkError(err)
+	}
+	return counter.count, nil
+}
+
+// yamlNodeCounter holds the state of countYAMLNodes.
+type yamlNodeCounter struct {
+	max   int
+	count int
+}
+
+func (c *yamlNodeCounter) add() error {
+	c.count++
+	if c.max > 0 && c.count > c.max {
+		return errYAMLTooLarge
+	}
+	return nil
+}
+
+// checkError returns the error that should be returned
+// by countYAMLNodes for the given decoding error.
+func (c *yamlNodeCounter) checkError(err error) error {
+	if strings.HasSuffix(err.Error(), "value contains itself") {
+		return &recursiveAliasError{err}
+	}
+	return err
+}
+
+// yamlNodeVisitor counts a YAML node and its children
+// with activeYAMLNodeCounter as it is unmarshaled.
+type yamlNodeVisitor struct{}
+
+// UnmarshalYAML implements yaml.Unmarshaler.
+func (*yamlNodeVisitor) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	c := activeYAMLNodeCounter
+	if err := c.add(); err != nil {
+		return err
+	}
+	// The children are counted as they are unmarshaled. Null values
+	// are never passed to an Unmarshaler, so they are left nil and
+	// counted here.
+	var seq []*yamlNodeVisitor
+	err := unmarshal(&seq)
+	if err == nil {
+		for _, n := range seq {
+			if n == nil {
+				if err := c.add(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if _, ok := err.(*yaml.TypeError); !ok {
+		return err
+	}
+	var m map[*yamlNodeVisitor]*yamlNodeVisitor
+	err = unmarshal(&m)
+	if err == nil {
+		for k, v := range m {
+			for _, n := range []*yamlNodeVisitor{k, v} {
+				if n == nil {
+					if err := c.add(); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		return nil
+	}
+	if _, ok := err.(*yaml.TypeError); !ok {
+		return err
+	}
+	// Anything else is a scalar.
+	return nil
+}